@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	// Name of a mod that exists on the channel.
+	ArgMod
+	// Player name or @mention, parsed into a User. Only mentions carry an ID,
+	// names have to be looked up by whoever handles the command.
+	ArgPlayer
+	// @mention of a user or a role, parsed into a Mentionable.
+	ArgMentionable
+	// Match number or mod name. Which match that is depends on when the
+	// command runs, so it's looked up by the handler.
+	ArgMatch
+	// Score such as 3-1, red first.
+	ArgScore
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case ArgInt:
+		return "number"
+	case ArgMod:
+		return "mod"
+	case ArgPlayer:
+		return "player name or @mention"
+	case ArgMentionable:
+		return "@role or @user"
+	case ArgMatch:
+		return "match number, or mod for its last match"
+	case ArgScore:
+		return "score, e.g. 3-1"
+	}
+	return "text"
+}
+
+// Arg describes a single argument of a command.
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+	// Consumes all remaining words. Only allowed for the last argument, and
+	// only for numbers and players.
+	Variadic bool
+	// The only values a text argument accepts, if set.
+	Choices []string
+	// Autocomplete values for slash commands. Mods and players are suggested
+	// without setting this.
+	Suggest func(b *Bot, ctx *Context) []Suggestion
+}
+
+// Suggestion is an autocomplete entry: what the user sees and what gets filled in.
+type Suggestion struct {
+	Name  string
+	Value string
+}
+
+// Describe returns what the argument expects, e.g. "number" or "add, remove or list".
+func (a Arg) Describe() string {
+	switch len(a.Choices) {
+	case 0:
+		return a.Type.String()
+	case 1:
+		return a.Choices[0]
+	}
+	return strings.Join(a.Choices[:len(a.Choices)-1], ", ") + " or " + a.Choices[len(a.Choices)-1]
+}
+
+func (a Arg) Usage() string {
+	name := a.Name
+	if a.Variadic {
+		name += "..."
+	}
+	if a.Optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// ArgError is returned when the words following a command don't match its
+// arguments. The message is meant to be shown to the user.
+type ArgError struct {
+	Message string
+}
+
+func (e *ArgError) Error() string {
+	return e.Message
+}
+
+func argErrorf(format string, a ...interface{}) *ArgError {
+	return &ArgError{fmt.Sprintf(format, a...)}
+}
+
+// Args holds the parsed arguments of a command, one entry per Arg in the
+// command's schema. Missing optional arguments are nil and variadic arguments
+// are stored as a slice.
+type Args []interface{}
+
+func (args Args) Has(i int) bool {
+	return args[i] != nil
+}
+
+// String returns a text, mod, match or score argument.
+func (args Args) String(i int) string {
+	if v, ok := args[i].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (args Args) Int(i int) int {
+	if v, ok := args[i].(int); ok {
+		return v
+	}
+	return 0
+}
+
+func (args Args) User(i int) User {
+	if v, ok := args[i].(User); ok {
+		return v
+	}
+	return User{}
+}
+
+func (args Args) Mentionable(i int) Mentionable {
+	if v, ok := args[i].(Mentionable); ok {
+		return v
+	}
+	return Mentionable{}
+}
+
+func (args Args) Users(i int) []User {
+	if v, ok := args[i].([]User); ok {
+		return v
+	}
+	return nil
+}
+
+func (args Args) Ints(i int) []int {
+	if v, ok := args[i].([]int); ok {
+		return v
+	}
+	return nil
+}
+
+// argParser converts words into typed arguments. Some types need to know
+// where the command was sent, e.g. to check that a mod exists.
+type argParser struct {
+	channel  *Channel
+	mentions []User
+}
+
+// Converts the words following a command according to `schema`.
+func (p *argParser) parse(schema []Arg, words []string) (Args, error) {
+	args := make(Args, len(schema))
+	for i, arg := range schema {
+		if arg.Variadic {
+			rest := words[min(i, len(words)):]
+			if len(rest) == 0 && !arg.Optional {
+				return nil, argErrorf("Missing %s", arg.Name)
+			}
+			values, err := p.parseVariadic(arg, rest)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = values
+			return args, nil
+		}
+		if i >= len(words) {
+			if !arg.Optional {
+				return nil, argErrorf("Missing %s", arg.Name)
+			}
+			continue
+		}
+		value, err := p.parseArg(arg, words[i])
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	if len(words) > len(schema) {
+		return nil, argErrorf("Too many arguments, didn't expect %s", strings.Join(words[len(schema):], " "))
+	}
+	return args, nil
+}
+
+// Converts slash command options, keyed by argument name, according to
+// `schema`. Unlike words, options can leave out any optional argument, not
+// only the last ones. Variadic options are one space separated string.
+func (p *argParser) parseOptions(schema []Arg, options map[string]string) (Args, error) {
+	args := make(Args, len(schema))
+	for i, arg := range schema {
+		option, ok := options[arg.Name]
+		if !ok {
+			if !arg.Optional {
+				return nil, argErrorf("Missing %s", arg.Name)
+			}
+			continue
+		}
+		var value interface{}
+		var err error
+		if arg.Variadic {
+			value, err = p.parseVariadic(arg, strings.Fields(option))
+		} else {
+			value, err = p.parseArg(arg, option)
+		}
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return args, nil
+}
+
+func (p *argParser) parseVariadic(arg Arg, words []string) (interface{}, error) {
+	var ints []int
+	var users []User
+	for _, word := range words {
+		value, err := p.parseArg(arg, word)
+		if err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case int:
+			ints = append(ints, v)
+		case User:
+			users = append(users, v)
+		}
+	}
+	if arg.Type == ArgPlayer {
+		return users, nil
+	}
+	return ints, nil
+}
+
+var (
+	mentionRegexp     = regexp.MustCompile(`^<@!?(\d+)>$`)
+	roleMentionRegexp = regexp.MustCompile(`^<@&(\d+)>$`)
+)
+
+func (p *argParser) parseArg(arg Arg, word string) (interface{}, error) {
+	word = strings.Trim(word, `"'`)
+	switch arg.Type {
+	case ArgInt:
+		value, err := strconv.Atoi(word)
+		if err != nil {
+			return nil, argErrorf("%s should be a number, got `%s`", arg.Name, word)
+		}
+		return value, nil
+	case ArgMod:
+		if p.channel == nil {
+			return word, nil
+		}
+		if _, ok := p.channel.Mods[word]; !ok {
+			return nil, argErrorf("Unknown mod `%s`. Mods on this channel: %s", word, p.modNames())
+		}
+		return word, nil
+	case ArgPlayer:
+		if match := mentionRegexp.FindStringSubmatch(word); match != nil {
+			for _, user := range p.mentions {
+				if user.ID == match[1] {
+					return user, nil
+				}
+			}
+			return User{ID: match[1]}, nil
+		}
+		return User{Username: strings.TrimPrefix(word, "@")}, nil
+	case ArgMentionable:
+		if match := roleMentionRegexp.FindStringSubmatch(word); match != nil {
+			return Mentionable{ID: match[1], Role: true}, nil
+		}
+		if match := mentionRegexp.FindStringSubmatch(word); match != nil {
+			return Mentionable{ID: match[1]}, nil
+		}
+		return nil, argErrorf("%s should be an @role or @user mention, got `%s`", arg.Name, word)
+	case ArgScore:
+		score, ok := parseScore(word)
+		if !ok {
+			return nil, argErrorf("%s should look like 3-1, red first, got `%s`", arg.Name, word)
+		}
+		return score, nil
+	}
+	if len(arg.Choices) > 0 {
+		for _, choice := range arg.Choices {
+			if strings.EqualFold(word, choice) {
+				return choice, nil
+			}
+		}
+		return nil, argErrorf("%s should be %s, got `%s`", arg.Name, arg.Describe(), word)
+	}
+	return word, nil
+}
+
+func (p *argParser) modNames() string {
+	var names []string
+	for name := range p.channel.Mods {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
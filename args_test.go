@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	channel := &Channel{Mods: map[string]*Mod{"ctf": {MaxPlayers: 8}}}
+	schema := []Arg{
+		{Name: "mod", Type: ArgMod, Optional: true},
+		{Name: "count", Type: ArgInt, Optional: true},
+		{Name: "players", Type: ArgPlayer, Variadic: true, Optional: true},
+	}
+	tests := []struct {
+		name    string
+		options map[string]string
+		want    Args
+		err     bool
+	}{
+		{"none", map[string]string{}, Args{nil, nil, nil}, false},
+		{"first only", map[string]string{"mod": "ctf"}, Args{"ctf", nil, nil}, false},
+		{"later without earlier", map[string]string{"count": "5"}, Args{nil, 5, nil}, false},
+		{"variadic", map[string]string{"players": "alice bob"}, Args{nil, nil, []User{{Username: "alice"}, {Username: "bob"}}}, false},
+		{"invalid", map[string]string{"count": "many"}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := argParser{channel: channel}
+			args, err := parser.parseOptions(schema, test.options)
+			if (err != nil) != test.err {
+				t.Fatalf("err = %v, want an error: %v", err, test.err)
+			}
+			if !test.err && !reflect.DeepEqual(args, test.want) {
+				t.Errorf("args = %#v, want %#v", args, test.want)
+			}
+		})
+	}
+}
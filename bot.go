@@ -1,15 +1,12 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"strings"
 	"sync"
 	"time"
 
-	"cloud.google.com/go/firestore"
-	"github.com/bwmarrin/discordgo"
 	"github.com/jasonlvhit/gocron"
 )
 
@@ -17,20 +14,123 @@ const DefaultTimeout = 5
 
 // Bot holds the bot state.
 type Bot struct {
-	channels  map[string]*Channel
-	games     map[GameIdentifier]*Game
-	client    *firestore.Client
-	ctx       context.Context
+	// Guards everything below. Commands, countdowns and the cleanup of idle
+	// players each hold it while they run, so they never interleave.
+	//
+	// A single lock rather than a goroutine per channel or game: commands
+	// like .leaveall, .stats or the ratings span mods and channels, and the
+	// timers change the same games as commands do. Handlers can stay plain
+	// code that reads and changes state directly. Messages are queued while
+	// the lock is held and sent after it is released, see lock and unlock,
+	// so Discord being slow doesn't keep others waiting for the lock. The
+	// goroutine that sends them does wait, also for messages other
+	// goroutines queued first, which is why commands are answered before
+	// their messages are sent, see dispatch.
+	mutex    sync.Mutex
+	channels map[string]*Channel
+	games    map[GameIdentifier]*Game
+	// All finished matches, ordered by ID.
+	matches []*Match
+	// Ratings by user ID, computed from the matches of each mod.
+	ratings map[GameIdentifier]map[string]*Rating
+	storage Storage
+	// Queues everything sent through `messenger` until the mutex is released.
+	outbox    *outbox
+	messenger Messenger
 	scheduler *gocron.Scheduler
 }
 
+// NewBot creates a bot and restores the channels and games kept in `storage`.
+func NewBot(storage Storage, messenger Messenger) (*Bot, error) {
+	channels, err := storage.Channels()
+	if err != nil {
+		return nil, fmt.Errorf("loading channels: %w", err)
+	}
+	storedGames, err := storage.Games()
+	if err != nil {
+		return nil, fmt.Errorf("loading games: %w", err)
+	}
+	matches, err := storage.Matches()
+	if err != nil {
+		return nil, fmt.Errorf("loading matches: %w", err)
+	}
+	ratings, err := storage.Ratings()
+	if err != nil {
+		return nil, fmt.Errorf("loading ratings: %w", err)
+	}
+	// Restore games that were in progress and start empty ones for the rest.
+	games := make(map[GameIdentifier]*Game)
+	for channelID, c := range channels {
+		for name := range c.Mods {
+			g := GameIdentifier{channelID, name}
+			if game, ok := storedGames[g]; ok && game != nil {
+				game.initialize()
+				games[g] = game
+			} else {
+				games[g] = NewGame()
+			}
+		}
+	}
+	outbox := newOutbox(messenger)
+	return &Bot{channels: channels, games: games, matches: matches, ratings: ratings, storage: storage, outbox: outbox, messenger: outbox, scheduler: gocron.NewScheduler()}, nil
+}
+
+// Takes the mutex. Every goroutine that reads or changes the bot's state does
+// so between lock and unlock.
+func (b *Bot) lock() {
+	b.mutex.Lock()
+}
+
+// Releases the mutex and sends the messages queued while it was held.
+func (b *Bot) unlock() {
+	b.mutex.Unlock()
+	b.outbox.flush()
+}
+
+// Start resumes interrupted countdowns and starts timing out idle players.
+func (b *Bot) Start() {
+	b.lock()
+	b.resumeCountdowns()
+	b.unlock()
+	b.scheduler.Every(5).Second().Do(b.cleanupPlayers)
+	b.scheduler.Start()
+}
+
 type Channel struct {
-	Mods    map[string]*Mod
-	Timeout int
+	Mods        map[string]*Mod
+	Timeout     int
+	Permissions Permissions
 }
 
 type Mod struct {
 	MaxPlayers int
+	// Name of the rating system, the default one if empty.
+	RatingSystem string
+	// Matches before this don't count for ratings.
+	RatingsSince time.Time
+	// Ratings admins gave players, oldest first.
+	RatingAdjustments []RatingAdjustment
+	// Matches a player needs to be on the leaderboard, DefaultMinGames if nil.
+	// Mods added before the setting existed have none stored.
+	MinGames *int
+	// How teams are formed, drafted by captains if empty.
+	TeamMode string
+	// How missing captains are chosen, the default strategy if empty.
+	CaptainStrategy string
+	// Players with this role are preferred by the "role" captain strategy.
+	CaptainRole string
+	// Turns of the captains as a sequence like ABBA, the default one if empty.
+	PickOrder string
+	// How long players have to volunteer as captain, CaptainCountdown if nil.
+	// Captains are picked right away if it's 0.
+	Countdown *time.Duration
+	// How long players have to confirm they are ready once the mod fills, no
+	// ready check if 0.
+	ReadyCheck time.Duration
+	// How long captains have for each turn, no limit if 0.
+	PickTime time.Duration
+	// What happens when a captain runs out of time, the default if empty.
+	IdleCaptain string
 }
 
 type GameIdentifier struct {
@@ -39,14 +139,20 @@ type GameIdentifier struct {
 }
 
 type PlayerMetadata struct {
+	// Display name of the player when they joined
+	Name          string
 	NotifyOnFill  bool
 	JoinTime      time.Time
 	LastSeenTime  time.Time
 	PickingNumber int
 	PickedOrder   int
+	// Role IDs the player had when they joined, if they joined themselves.
+	Roles []string
+	// Whether the player confirmed the ready check of the filled game.
+	Ready bool
 }
 
-// Used for sorting for display
+// Used for sorting for display. Key is the user ID.
 type Player struct {
 	Key   string
 	Value *PlayerMetadata
@@ -56,190 +162,194 @@ type TeamColor bool
 
 const (
 	Red  TeamColor = false
-	Blue           = true
+	Blue TeamColor = true
 )
 
 // Bot commands
 
-func (b *Bot) Enable(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if !isAdmin(s, m) {
-		log.Printf("%s tried enabling bot on channel but is not an admin", m.Author.Username)
-		return
-	}
-	if _, ok := b.channels[m.ChannelID]; ok {
-		s.ChannelMessageSend(m.ChannelID, "Pugbot was already enabled")
+func (b *Bot) Enable(ctx *Context) {
+	if _, ok := b.channels[ctx.ChannelID]; ok {
+		ctx.Reply("Pugbot was already enabled")
 	} else {
-		c := Channel{make(map[string]*Mod), DefaultTimeout}
-		b.client.Collection("channels").Doc(m.ChannelID).Set(b.ctx, c)
-		b.channels[m.ChannelID] = &c
-		s.ChannelMessageSend(m.ChannelID, "Pugbot enabled")
+		c := Channel{Mods: make(map[string]*Mod), Timeout: DefaultTimeout}
+		b.channels[ctx.ChannelID] = &c
+		b.saveChannel(ctx.ChannelID)
+		ctx.Reply("Pugbot enabled")
 	}
 }
 
-func (b *Bot) Disable(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if !isAdmin(s, m) {
-		log.Printf("%s tried enabling bot on channel but is not an admin", m.Author.Username)
-		return
-	}
-	if _, ok := b.channels[m.ChannelID]; ok {
-		delete(b.channels, m.ChannelID)
-		s.ChannelMessageSend(m.ChannelID, "Pugbot disabled")
-		b.client.Collection("channels").Doc(m.ChannelID).Delete(b.ctx)
+func (b *Bot) Disable(ctx *Context) {
+	if _, ok := b.channels[ctx.ChannelID]; ok {
+		delete(b.channels, ctx.ChannelID)
+		ctx.Reply("Pugbot disabled")
+		if err := b.storage.DeleteChannel(ctx.ChannelID); err != nil {
+			log.Printf("Failed to delete channel %s: %s", ctx.ChannelID, err)
+		}
 		var gamesToDelete []GameIdentifier
 		for game := range b.games {
-			if game.Channel == m.ChannelID {
+			if game.Channel == ctx.ChannelID {
 				gamesToDelete = append(gamesToDelete, game)
 			}
 		}
 		for _, game := range gamesToDelete {
 			delete(b.games, game)
+			if err := b.storage.DeleteGame(game); err != nil {
+				log.Printf("Failed to delete game %v: %s", game, err)
+			}
 		}
 	}
 }
 
-func (b *Bot) Addmod(s *discordgo.Session, m *discordgo.MessageCreate, name string, maxPlayers int) {
-	if !isAdmin(s, m) {
-		log.Printf("%s tried adding mod on channel but is not an admin", m.Author.Username)
-		return
-	}
-	if c, ok := b.channels[m.ChannelID]; ok {
+func (b *Bot) Addmod(ctx *Context, name string, maxPlayers int) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
 		if _, ok := c.Mods[name]; ok {
-			s.ChannelMessageSend(m.ChannelID, "Mod with this name already exists")
+			ctx.Reply("Mod with this name already exists")
 			log.Println("Mod with this name already exists")
 		} else if maxPlayers%2 != 0 || maxPlayers == 0 {
-			s.ChannelMessageSend(m.ChannelID, "Invalid player count")
+			ctx.Reply("Invalid player count")
 			log.Println("Invalid player count")
 		} else {
-			mod := Mod{maxPlayers}
+			mod := Mod{MaxPlayers: maxPlayers}
 			c.Mods[name] = &mod
-			g := GameIdentifier{m.ChannelID, name}
-			b.games[g] = &Game{Players: make(map[string]*PlayerMetadata), Red: make(map[string]*PlayerMetadata), Blue: make(map[string]*PlayerMetadata), mutex: new(sync.Mutex), RedCaptain: new(string), BlueCaptain: new(string)}
-			_, err := b.client.Collection("channels").Doc(m.ChannelID).Set(b.ctx, map[string]interface{}{
-				"Mods": c.Mods,
-			}, firestore.MergeAll)
-			if err != nil {
-				// Handle any errors in an appropriate way, such as returning them.
-				log.Printf("An error has occurred: %s", err)
+			g := GameIdentifier{ctx.ChannelID, name}
+			b.games[g] = NewGame()
+			b.saveGame(g)
+			if !b.saveChannel(ctx.ChannelID) {
 				return
 			}
-			s.MessageReactionAdd(m.ChannelID, m.Message.ID, "✅")
+			ctx.Ack()
 		}
 	} else {
-		s.ChannelMessageSend(m.ChannelID, "Pugbot is not enabled on this channel")
+		ctx.Reply("Pugbot is not enabled on this channel")
 	}
 }
 
-func (b *Bot) Settimeout(s *discordgo.Session, m *discordgo.MessageCreate, timeoutInHours int) {
-	if !isAdmin(s, m) {
-		log.Printf("%s tried setting timeout on channel but is not an admin", m.Author.Username)
-		return
-	}
-	if c, ok := b.channels[m.ChannelID]; ok {
+func (b *Bot) Settimeout(ctx *Context, timeoutInHours int) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
 		c.Timeout = timeoutInHours
-		_, err := b.client.Collection("channels").Doc(m.ChannelID).Set(b.ctx, map[string]interface{}{
-			"Timeout": timeoutInHours,
-		}, firestore.MergeAll)
-		if err != nil {
-			// Handle any errors in an appropriate way, such as returning them.
-			log.Printf("An error has occurred: %s", err)
+		if !b.saveChannel(ctx.ChannelID) {
 			return
 		}
-		s.ChannelMessageSend(m.ChannelID, "Timeout set")
+		ctx.Reply("Timeout set")
 	}
 }
 
-func (b *Bot) Gettimeout(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if c, ok := b.channels[m.ChannelID]; ok {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Timeout is set to %d minutes", c.Timeout))
+func (b *Bot) Gettimeout(ctx *Context) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		ctx.Reply(fmt.Sprintf("Timeout is set to %d minutes", c.Timeout))
 	}
 }
 
-func (b *Bot) Join(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	b.Addplayer(s, m, name, m.Author.Username)
+func (b *Bot) Join(ctx *Context, name string) {
+	b.Addplayer(ctx, name, ctx.User)
 }
 
-func (b *Bot) Addplayer(s *discordgo.Session, m *discordgo.MessageCreate, name string, playerNames ...string) {
-	gameID, mod := b.GameInfo(m.ChannelID, name)
+func (b *Bot) Addplayer(ctx *Context, name string, players ...User) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, name)
 	if gameID == nil || mod == nil {
 		return
 	}
-	if m.Author.Username != playerNames[0] {
-		if !isAdmin(s, m) {
-			log.Printf("%s tried adding player %s but is not an admin", m.Author.Username, playerNames[0])
+	for _, player := range players {
+		if player.ID == "" {
+			ctx.Fail(fmt.Sprintf("Mention players to add them, e.g. `.addplayer %s @%s`", name, player.Username))
+			return
+		}
+		if player.ID != ctx.User.ID && !b.hasPermission(ctx, PermissionModerator) {
+			log.Printf("%s tried adding player %s but is not a moderator", ctx.User.Username, player.ID)
 			return
 		}
 	}
 
 	if _, ok := b.games[*gameID]; !ok {
-		b.games[*gameID] = &Game{Players: make(map[string]*PlayerMetadata), Blue: make(map[string]*PlayerMetadata), Red: make(map[string]*PlayerMetadata), mutex: new(sync.Mutex), RedCaptain: new(string), BlueCaptain: new(string)}
+		b.games[*gameID] = NewGame()
 	}
 	game := b.games[*gameID]
-
-	game.mutex.Lock()
-	defer game.mutex.Unlock()
-	for _, playerName := range playerNames {
+	wasFull := game.IsFull(mod)
+	var waiting []string
+	for _, player := range players {
+		var roles []string
+		if player.ID == ctx.User.ID {
+			roles = ctx.Roles
+		}
 		if game.IsFull(mod) {
+			if !game.isPlaying(player.ID) {
+				position := game.addWaiting(player, roles)
+				waiting = append(waiting, fmt.Sprintf("**%s** is full, %s is **#%d** on the waiting list", name, game.Waiting[position-1].Value.Name, position))
+			}
 			continue
 		}
-		game.AddPlayer(playerName)
+		game.AddPlayer(player)
+		if roles != nil {
+			game.Players[player.ID].Roles = roles
+		}
 	}
 
-	if game.IsFull(mod) {
-		game.BeginPicks(s, m.ChannelID, name, mod)
-	} else {
-		b.List(s, m, name)
+	if game.IsFull(mod) && !wasFull {
+		b.beginPicks(*gameID, mod)
+	} else if !game.IsFull(mod) {
+		b.List(ctx, name)
 	}
+	if len(waiting) > 0 {
+		ctx.ReplyLines(waiting)
+	}
+	b.saveGame(*gameID)
 }
 
-func (b *Bot) Reset(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	if !isAdmin(s, m) {
-		log.Printf("%s tried resetting but is not an admin", m.Author.Username)
-		return
-	}
-	gameID, mod := b.GameInfo(m.ChannelID, name)
+func (b *Bot) Reset(ctx *Context, name string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, name)
 	if gameID == nil || mod == nil {
 		return
 	}
 	if game, ok := b.games[*gameID]; ok {
-		s.ChannelMessageSend(m.ChannelID, "Reset!")
-		game.AddPlayer(*game.RedCaptain)
-		game.AddPlayer(*game.BlueCaptain)
+		ctx.Reply("Reset!")
+		for _, team := range []map[string]*PlayerMetadata{game.Red, game.Blue} {
+			for id, player := range team {
+				player.PickedOrder = 0
+				game.Players[id] = player
+			}
+		}
+		for _, player := range game.Players {
+			player.PickingNumber = 0
+		}
 		game.Red = make(map[string]*PlayerMetadata)
 		game.Blue = make(map[string]*PlayerMetadata)
 		game.RedCaptain = new(string)
 		game.BlueCaptain = new(string)
+		game.CaptainsTime = time.Time{}
+		game.PickDeadline = time.Time{}
+		game.endReadyCheck()
+		game.Picks = nil
 		if game.IsFull(mod) {
-			game.BeginPicks(s, m.ChannelID, name, mod)
+			b.beginPicks(*gameID, mod)
 		} else {
-			b.List(s, m, name)
+			b.List(ctx, name)
+			b.refill(*gameID)
 		}
+		b.saveGame(*gameID)
 	}
 }
 
-func (b *Bot) Teams(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	gameID, mod := b.GameInfo(m.ChannelID, name)
+func (b *Bot) Teams(ctx *Context, name string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, name)
 	if gameID == nil || mod == nil {
 		return
 	}
 	if game, ok := b.games[*gameID]; ok {
 		if !game.IsPickingTeams(mod) {
+			b.Last(ctx, name, 0, 1)
 			return
 		}
-		b.teams(s, m, *gameID)
+		b.teams(ctx, *gameID)
 	}
 }
 
-func (b *Bot) P(s *discordgo.Session, m *discordgo.MessageCreate, playerIDs ...int) {
-	b.Pick(s, m, playerIDs...)
-}
-
-func (b *Bot) Pick(s *discordgo.Session, m *discordgo.MessageCreate, playerIDs ...int) {
-	if c, ok := b.channels[m.ChannelID]; ok {
+func (b *Bot) Pick(ctx *Context, pickingNumbers ...int) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
 		count := 0
 		var pickingModName string
 		var game *Game
 		for modName := range c.Mods {
-			gameID, mod := b.GameInfo(m.ChannelID, modName)
+			gameID, mod := b.GameInfo(ctx.ChannelID, modName)
 			if _, ok := b.games[*gameID]; !ok {
 				continue
 			}
@@ -252,28 +362,28 @@ func (b *Bot) Pick(s *discordgo.Session, m *discordgo.MessageCreate, playerIDs .
 			count++
 		}
 		if count == 1 {
-			var playerNames []string
-			for _, playerID := range playerIDs {
-				playerName := game.NameByPickingNumber(playerID)
-				log.Printf(playerName)
-				if playerName == "" {
+			var players []User
+			for _, pickingNumber := range pickingNumbers {
+				id := game.IDByPickingNumber(pickingNumber)
+				if id == "" {
+					ctx.Fail(fmt.Sprintf("Nobody left to pick has number %d", pickingNumber))
 					return
 				}
-				playerNames = append(playerNames, playerName)
+				players = append(players, User{ID: id})
 			}
-			b.Pickname(s, m, pickingModName, playerNames...)
+			b.Pickname(ctx, pickingModName, players...)
 		} else if count > 1 {
-			s.ChannelMessageSend(m.ChannelID, "More than one game running in parallel, picking use .pickname <mod> <player name>")
+			ctx.Reply("More than one game running in parallel, picking use .pickname <mod> <player name>")
 		}
 	}
 }
 
-func (b *Bot) Pn(s *discordgo.Session, m *discordgo.MessageCreate, playerNames ...string) {
-	if c, ok := b.channels[m.ChannelID]; ok {
+func (b *Bot) Pn(ctx *Context, players ...User) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
 		count := 0
 		var pickingModName string
 		for modName := range c.Mods {
-			gameID, mod := b.GameInfo(m.ChannelID, modName)
+			gameID, mod := b.GameInfo(ctx.ChannelID, modName)
 			if _, ok := b.games[*gameID]; !ok {
 				continue
 			}
@@ -286,16 +396,16 @@ func (b *Bot) Pn(s *discordgo.Session, m *discordgo.MessageCreate, playerNames .
 			count++
 		}
 		if count == 1 {
-			b.Pickname(s, m, pickingModName, playerNames...)
+			b.Pickname(ctx, pickingModName, players...)
 		} else if count > 1 {
-			s.ChannelMessageSend(m.ChannelID, "More than one game running in parallel, picking use .pick <mod> <player>")
+			ctx.Reply("More than one game running in parallel, picking use .pick <mod> <player>")
 		}
 	}
 }
 
-func (b *Bot) Pickname(s *discordgo.Session, m *discordgo.MessageCreate, modName string, playerNames ...string) {
-	gameID, mod := b.GameInfo(m.ChannelID, modName)
-	if gameID == nil || mod == nil || len(playerNames) > 2 {
+func (b *Bot) Pickname(ctx *Context, modName string, players ...User) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, modName)
+	if gameID == nil || mod == nil {
 		return
 	}
 
@@ -309,63 +419,97 @@ func (b *Bot) Pickname(s *discordgo.Session, m *discordgo.MessageCreate, modName
 		return
 	}
 
-	for _, playerName := range playerNames {
-		if !game.HasPlayer(playerName) {
+	var playerIDs []string
+	for _, player := range players {
+		id := game.FindPlayer(player)
+		if id == "" {
+			ctx.Fail(fmt.Sprintf("%s isn't left to pick in **%s**", player.DisplayName(), modName))
 			return
 		}
+		for _, picked := range playerIDs {
+			if picked == id {
+				ctx.Fail(fmt.Sprintf("%s can only be picked once", game.Players[id].Name))
+				return
+			}
+		}
+		playerIDs = append(playerIDs, id)
 	}
 
-	// 0 is red, 1 is blue
-	for _, playerName := range playerNames {
-		playerMetadata := game.Players[playerName]
-		pickColor := game.PickColor()
-		playerMetadata.PickedOrder = game.PickedPlayerCount()
-		if pickColor == Red && (*game.RedCaptain == m.Message.Author.Username || m.Message.Author.Username == "hyperreal" || m.Message.Author.Username == "dc") {
-			game.Red[playerName] = playerMetadata
-			delete(game.Players, playerName)
-		}
-		if pickColor == Blue && (*game.BlueCaptain == m.Message.Author.Username || m.Message.Author.Username == "hyperreal" || m.Message.Author.Username == "dc") {
-			game.Blue[playerName] = playerMetadata
-			delete(game.Players, playerName)
-		}
+	pickColor, picks := game.PickTurn(mod)
+	if game.captain(pickColor) != ctx.User.ID && !b.hasPermission(ctx, PermissionReferee) {
+		ctx.Fail(fmt.Sprintf("It's %s's turn to pick", game.team(pickColor)[game.captain(pickColor)].Name))
+		return
 	}
-	if len(game.Players) == 1 {
-		lastPlayerName, lastPlayerMetadata := getAnyPlayer(game.Players)
-		delete(game.Players, lastPlayerName)
-		lastPlayerMetadata.PickedOrder = game.PickedPlayerCount()
-		if len(game.Red) < len(game.Blue) {
-			game.Red[lastPlayerName] = lastPlayerMetadata
-		} else {
-			game.Blue[lastPlayerName] = lastPlayerMetadata
-		}
-		b.teamsSelected(s, m, *gameID)
+	if len(playerIDs) > picks {
+		ctx.Fail(fmt.Sprintf("%s picks %d now, not %d", pickColor, picks, len(playerIDs)))
+		return
+	}
+	for _, playerID := range playerIDs {
+		game.Pick(playerID, pickColor)
+	}
+	b.saveGame(*gameID)
+	if len(game.Players) <= 1 {
+		game.pickLast(mod)
+		b.teamsSelected(ctx, *gameID)
 	} else {
-		nextPickColor := game.PickColor()
-		toPick := *game.BlueCaptain
-		if nextPickColor == Red {
-			toPick = *game.RedCaptain
+		b.List(ctx, modName)
+		b.teams(ctx, *gameID)
+		ctx.Reply(game.toPick(mod))
+		// Picking part of a turn doesn't buy the captain more time for the rest.
+		if next, _ := game.PickTurn(mod); next != pickColor {
+			b.startPickTimer(*gameID)
 		}
-		b.List(s, m, modName)
-		b.teams(s, m, *gameID)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s to pick", toPick))
 	}
 }
 
-func (b *Bot) J(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	b.Join(s, m, name)
-}
-
-func (b *Bot) Jp(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	b.Joinpm(s, m, name)
+// Unpick undoes the last pick of a mod, or of the only mod that is picking.
+func (b *Bot) Unpick(ctx *Context, modName string) {
+	if modName == "" {
+		for name, mod := range b.channels[ctx.ChannelID].Mods {
+			if game, ok := b.games[GameIdentifier{ctx.ChannelID, name}]; ok && game.IsPickingTeams(mod) {
+				if modName != "" {
+					ctx.Fail(fmt.Sprintf("More than one game is picking, use `%sunpick <mod>`", CommandPrefix))
+					return
+				}
+				modName = name
+			}
+		}
+	}
+	gameID, mod := b.GameInfo(ctx.ChannelID, modName)
+	if gameID == nil || mod == nil || !b.games[*gameID].IsPickingTeams(mod) {
+		ctx.Fail("No game is picking")
+		return
+	}
+	game := b.games[*gameID]
+	if len(game.Picks) == 0 {
+		ctx.Fail(fmt.Sprintf("Nobody was picked in **%s** yet", modName))
+		return
+	}
+	last := game.Picks[len(game.Picks)-1]
+	if last.Captain != ctx.User.ID && !b.hasPermission(ctx, PermissionAdmin) {
+		ctx.Fail("Only the captain who made the last pick and admins can undo it")
+		return
+	}
+	if last.Player == *game.RedCaptain || last.Player == *game.BlueCaptain {
+		ctx.Fail(fmt.Sprintf("%s is captain now and can't be unpicked", game.team(last.Team)[last.Player].Name))
+		return
+	}
+	pick, _ := game.Unpick()
+	ctx.Reply(fmt.Sprintf("%s is back to pick from", game.Players[pick.Player].Name))
+	b.List(ctx, modName)
+	b.teams(ctx, *gameID)
+	ctx.Reply(game.toPick(mod))
+	b.startPickTimer(*gameID)
+	b.saveGame(*gameID)
 }
 
-func (b *Bot) Joinpm(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	b.Join(s, m, name)
-	b.Pm(s, m, name)
+func (b *Bot) Joinpm(ctx *Context, name string) {
+	b.Join(ctx, name)
+	b.Pm(ctx, name)
 }
 
-func (b *Bot) Pm(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	gameID, mod := b.GameInfo(m.ChannelID, name)
+func (b *Bot) Pm(ctx *Context, name string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, name)
 	if gameID == nil || mod == nil {
 		return
 	}
@@ -376,19 +520,18 @@ func (b *Bot) Pm(s *discordgo.Session, m *discordgo.MessageCreate, name string)
 
 	game := b.games[*gameID]
 
-	if metadata, ok := game.Players[m.Author.Username]; ok {
+	if metadata, ok := game.Players[ctx.User.ID]; ok {
 		if !metadata.NotifyOnFill {
-			game.mutex.Lock()
-			defer game.mutex.Unlock()
 			metadata.NotifyOnFill = true
-			game.Players[m.Author.Username] = metadata
-			s.MessageReactionAdd(m.ChannelID, m.Message.ID, "✅")
+			game.Players[ctx.User.ID] = metadata
+			b.saveGame(*gameID)
+			ctx.Ack()
 		}
 	}
 }
 
-func (b *Bot) Leave(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	gameID, mod := b.GameInfo(m.ChannelID, name)
+func (b *Bot) Leave(ctx *Context, name string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, name)
 	if gameID == nil || mod == nil {
 		return
 	}
@@ -398,42 +541,50 @@ func (b *Bot) Leave(s *discordgo.Session, m *discordgo.MessageCreate, name strin
 	}
 
 	game := b.games[*gameID]
-	if _, ok := game.Players[m.Author.Username]; ok {
-		game.mutex.Lock()
-		defer game.mutex.Unlock()
-		delete(game.Players, m.Author.Username)
-		b.List(s, m, name)
+	if _, ok := game.Players[ctx.User.ID]; ok && game.IsPickingTeams(mod) {
+		ctx.Fail(leavePickingMessage(name))
+	} else if ok {
+		delete(game.Players, ctx.User.ID)
+		b.saveGame(*gameID)
+		b.List(ctx, name)
+		b.refill(*gameID)
+	} else if game.removeWaiting(ctx.User.ID) {
+		b.saveGame(*gameID)
+		b.List(ctx, name)
 	}
 }
 
-func (b *Bot) L(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	b.Leave(s, m, name)
+// Players left to pick can't leave: the captains count on them and nobody
+// from the waiting list can take their place once picking started.
+func leavePickingMessage(name string) string {
+	return fmt.Sprintf("Teams of **%s** are being picked, you can't leave now. A moderator can `%sreset %s` it", name, CommandPrefix, name)
 }
 
-func (b *Bot) Leaveall(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if c, ok := b.channels[m.ChannelID]; ok {
-		for name := range c.Mods {
-			g := GameIdentifier{m.ChannelID, name}
+func (b *Bot) Leaveall(ctx *Context) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		for name, mod := range c.Mods {
+			g := GameIdentifier{ctx.ChannelID, name}
 			if _, ok := b.games[g]; !ok {
 				return
 			}
 
-			b.games[g].mutex.Lock()
-			defer b.games[g].mutex.Unlock()
-			if _, ok := b.games[g].Players[m.Author.Username]; ok {
-				delete(b.games[g].Players, m.Author.Username)
-				b.List(s, m, name)
+			if _, ok := b.games[g].Players[ctx.User.ID]; ok && b.games[g].IsPickingTeams(mod) {
+				ctx.Fail(leavePickingMessage(name))
+			} else if ok {
+				delete(b.games[g].Players, ctx.User.ID)
+				b.saveGame(g)
+				b.List(ctx, name)
+				b.refill(g)
+			} else if b.games[g].removeWaiting(ctx.User.ID) {
+				b.saveGame(g)
+				b.List(ctx, name)
 			}
 		}
 	}
 }
 
-func (b *Bot) Lva(s *discordgo.Session, m *discordgo.MessageCreate) {
-	b.Leaveall(s, m)
-}
-
-func (b *Bot) List(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	gameID, mod := b.GameInfo(m.ChannelID, name)
+func (b *Bot) List(ctx *Context, name string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, name)
 	if gameID == nil || mod == nil {
 		return
 	}
@@ -447,19 +598,18 @@ func (b *Bot) List(s *discordgo.Session, m *discordgo.MessageCreate, name string
 	var msg strings.Builder
 	fmt.Fprintf(&msg, "**%s** [%d / %d]\n", name, len(game.Players), mod.MaxPlayers)
 	fmt.Fprintf(&msg, game.BuildPlayerList())
+	if len(game.Waiting) > 0 {
+		fmt.Fprintf(&msg, "\nWaiting: %s", game.waitingList())
+	}
 
-	s.ChannelMessageSend(m.ChannelID, msg.String())
-}
-
-func (b *Bot) Lsa(s *discordgo.Session, m *discordgo.MessageCreate) {
-	b.ListAll(s, m)
+	ctx.Reply(msg.String())
 }
 
-func (b *Bot) ListAll(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if c, ok := b.channels[m.ChannelID]; ok {
+func (b *Bot) ListAll(ctx *Context) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
 		var modLists []string
 		for modName, mod := range c.Mods {
-			g := GameIdentifier{m.ChannelID, modName}
+			g := GameIdentifier{ctx.ChannelID, modName}
 			if _, ok := b.games[g]; !ok {
 				continue
 			}
@@ -468,23 +618,23 @@ func (b *Bot) ListAll(s *discordgo.Session, m *discordgo.MessageCreate) {
 		}
 
 		output := strings.Join(modLists, " :small_orange_diamond: ")
-		s.ChannelMessageSend(m.ChannelID, output)
+		ctx.Reply(output)
 	}
 }
 
-func (b *Bot) Ls(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	b.List(s, m, name)
-}
-
-func (b *Bot) Captain(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if c, ok := b.channels[m.ChannelID]; ok {
+func (b *Bot) Captain(ctx *Context) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
 		for modName, mod := range c.Mods {
-			g := GameIdentifier{m.ChannelID, modName}
+			g := GameIdentifier{ctx.ChannelID, modName}
 			if game, ok := b.games[g]; ok {
-				if game.HasPlayer(m.Author.Username) && game.IsFull(mod) && !game.IsPickingTeams(mod) {
-					playerMetadata := game.Players[m.Author.Username]
-					log.Printf(fmt.Sprintf("Setting captain to %s for %p", m.Author.Username, game))
-					s.ChannelMessageSend(m.ChannelID, game.SetNextCaptainIfPossible(m.Author.Username, playerMetadata))
+				if game.HasPlayer(ctx.User.ID) && game.IsFull(mod) && !game.IsPickingTeams(mod) && game.ReadyDeadline.IsZero() {
+					playerMetadata := game.Players[ctx.User.ID]
+					log.Printf("Setting captain to %s for %p", ctx.User.ID, game)
+					ctx.Reply(game.SetNextCaptainIfPossible(ctx.User.ID, playerMetadata))
+					if game.IsPickingTeams(mod) {
+						b.startPicking(g, nil)
+					}
+					b.saveGame(g)
 					return
 				}
 			}
@@ -492,37 +642,47 @@ func (b *Bot) Captain(s *discordgo.Session, m *discordgo.MessageCreate) {
 	}
 }
 
-func (b *Bot) Forcerandomcaptains(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	if !isAdmin(s, m) {
-		log.Printf("%s tried forcing random captains but is not an admin", m.Author.Username)
+func (b *Bot) Forcerandomcaptains(ctx *Context, name string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, name)
+	if gameID == nil || mod == nil {
 		return
 	}
-	g := GameIdentifier{m.ChannelID, name}
-	if game, ok := b.games[g]; ok {
-		game.AutoPickRemainingCaptains(s, m.ChannelID)
+	if game := b.games[*gameID]; !game.IsFull(mod) || game.IsPickingTeams(mod) || !game.ReadyDeadline.IsZero() {
+		ctx.Fail(fmt.Sprintf("**%s** isn't waiting for captains", name))
+		return
 	}
-}
-
-func (b *Bot) Frc(s *discordgo.Session, m *discordgo.MessageCreate, name string) {
-	b.Forcerandomcaptains(s, m, name)
+	b.pickRemainingCaptains(*gameID, mod, true)
+	b.saveGame(*gameID)
 }
 
 // Internal
 
-func (b *Bot) teamsSelected(s *discordgo.Session, m *discordgo.MessageCreate, g GameIdentifier) {
+func (b *Bot) teamsSelected(ctx *Context, g GameIdentifier) {
+	ctx.Reply(b.selectTeams(g))
+	b.refill(g)
+}
+
+// Records the match of a game whose teams are complete and starts a new game.
+// Returns the announcement of the teams.
+func (b *Bot) selectTeams(g GameIdentifier) string {
+	match := b.recordMatch(g)
 	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("Teams for **%s** were selected:\n", g.Mod))
+	builder.WriteString(fmt.Sprintf("Teams for **%s** were selected (match **#%d**):\n", g.Mod, match.ID))
 	builder.WriteString(b.games[g].Teams())
-	s.ChannelMessageSend(m.ChannelID, builder.String())
-	b.games[g] = &Game{Players: make(map[string]*PlayerMetadata), Red: make(map[string]*PlayerMetadata), Blue: make(map[string]*PlayerMetadata), mutex: new(sync.Mutex), RedCaptain: new(string), BlueCaptain: new(string)}
+	builder.WriteString(b.teamPrediction(g, b.games[g]))
+	// Whoever is still waiting queues for the next game, see refill.
+	waiting := b.games[g].Waiting
+	b.games[g] = NewGame()
+	b.games[g].Waiting = waiting
+	b.saveGame(g)
+	return builder.String()
 }
 
-func (b *Bot) teams(s *discordgo.Session, m *discordgo.MessageCreate, g GameIdentifier) {
+func (b *Bot) teams(ctx *Context, g GameIdentifier) {
 	if game, ok := b.games[g]; ok {
 		teams := game.Teams()
-		s.ChannelMessageSend(m.ChannelID, teams)
+		ctx.Reply(teams)
 	}
-	// TODO: Print teams of last game if picking isn't in progress
 }
 
 func (b *Bot) GameInfo(channelID string, modName string) (*GameIdentifier, *Mod) {
@@ -537,60 +697,198 @@ func (b *Bot) GameInfo(channelID string, modName string) (*GameIdentifier, *Mod)
 	return nil, nil
 }
 
-func isAdmin(s *discordgo.Session, m *discordgo.MessageCreate) bool {
-	if m.Author.Username == "hyperreal" {
-		return true
+// Persists the state of a game so it survives restarts.
+func (b *Bot) saveGame(g GameIdentifier) {
+	if game, ok := b.games[g]; ok {
+		if err := b.storage.SaveGame(g, game); err != nil {
+			log.Printf("Failed to save game %v: %s", g, err)
+		}
 	}
-	for _, roleID := range m.Member.Roles {
-		role, _ := s.State.Role(m.GuildID, roleID)
-		if role.Name == "Admin" {
-			return true
+}
+
+func (b *Bot) beginPicks(g GameIdentifier, mod *Mod) {
+	for id, player := range b.games[g].Players {
+		if player.NotifyOnFill {
+			b.messenger.DM(id, fmt.Sprintf("**%s** has filled in <#%s>", g.Mod, g.Channel))
 		}
 	}
-	return false
+	game := b.games[g]
+	game.FilledTime = time.Now()
+	b.messenger.Send(g.Channel, fmt.Sprintf("**%s** has filled: %s", g.Mod, game.MentionAll()))
+	if mod.ReadyCheck > 0 {
+		b.startReadyCheck(g, mod)
+		return
+	}
+	b.formTeams(g, mod)
 }
 
-func (b *Bot) cleanupPlayers(s *discordgo.Session) {
+// Forms the teams of a full game whose players are ready: right away for
+// balanced mods, after the captain countdown for drafted ones.
+func (b *Bot) formTeams(g GameIdentifier, mod *Mod) {
+	game := b.games[g]
+	if mod.teamMode() == TeamModeBalanced {
+		b.balanceGame(g, mod)
+		return
+	}
+	if mod.countdown() == 0 {
+		b.pickRemainingCaptains(g, mod, false)
+		return
+	}
+	game.CountdownEnd = time.Now().Add(mod.countdown())
+	b.runCountdown(g, mod)
+}
+
+// Shows the captain countdown of a filled game until `CountdownEnd`, then picks
+// the remaining captains. Used directly to resume a countdown that was
+// interrupted by a restart.
+func (b *Bot) runCountdown(g GameIdentifier, mod *Mod) {
+	game := b.games[g]
+	messageText := fmt.Sprintf("**%s** has filled.\nCaptains will be selected in `%d seconds`", g.Mod, game.countdownSeconds())
+	messageID, _ := b.messenger.Send(g.Channel, messageText)
+
+	end := game.CountdownEnd
+	countdownTicker := time.NewTicker(time.Second)
+	go func() {
+		defer countdownTicker.Stop()
+		for range countdownTicker.C {
+			if !b.tickCountdown(g, game, mod, end, messageID) {
+				return
+			}
+		}
+	}()
+}
+
+// Updates the countdown message of `game`. Returns false once the countdown is over.
+func (b *Bot) tickCountdown(g GameIdentifier, game *Game, mod *Mod, end time.Time, messageID string) bool {
+	b.lock()
+	defer b.unlock()
+	// The game was finished, reset or disabled in the meantime.
+	if b.games[g] != game {
+		return false
+	}
+	seconds := int(time.Until(end).Round(time.Second).Seconds())
+	log.Printf("Ticking %d for %p", seconds, game)
+
+	switch {
+	case !game.IsFull(mod):
+		b.messenger.Edit(g.Channel, messageID, fmt.Sprintf("**%s** has filled.\n~~Captains will be selected in `%d seconds`~~", g.Mod, seconds))
+		return false
+	case game.IsPickingTeams(mod):
+		b.messenger.Edit(g.Channel, messageID, fmt.Sprintf("**%s** has filled.\nCaptains have been selected", g.Mod))
+		return false
+	// The game filled again after someone left, another countdown took over.
+	case !game.CountdownEnd.Equal(end):
+		b.messenger.Edit(g.Channel, messageID, fmt.Sprintf("**%s** has filled.\n~~Captains will be selected in `%d seconds`~~", g.Mod, seconds))
+		return false
+	case seconds <= 0:
+		b.messenger.Edit(g.Channel, messageID, fmt.Sprintf("**%s** has filled.\nCaptains have been selected", g.Mod))
+		b.pickRemainingCaptains(g, mod, false)
+		b.saveGame(g)
+		return false
+	case seconds%countdownUpdateSeconds(mod.countdown()) == 0 || seconds < 5:
+		b.messenger.Edit(g.Channel, messageID, fmt.Sprintf("**%s** has filled.\nCaptains will be selected in `%d seconds`", g.Mod, seconds))
+	}
+	return true
+}
+
+// Restarts the captain countdowns and pick timers of games that were running
+// before the bot was restarted.
+func (b *Bot) resumeCountdowns() {
+	for g, game := range b.games {
+		mod := b.channels[g.Channel].Mods[g.Mod]
+		if game.IsPickingTeams(mod) && !game.PickDeadline.IsZero() {
+			b.runPickTimer(g, mod)
+			continue
+		}
+		if !game.IsFull(mod) || game.IsPickingTeams(mod) {
+			game.endReadyCheck()
+			continue
+		}
+		// A full game without a running ready check or countdown is waiting for
+		// captains to volunteer, which needs no timer.
+		if !game.ReadyDeadline.IsZero() {
+			b.runReadyCheck(g, mod)
+		} else if !game.CountdownEnd.IsZero() {
+			b.runCountdown(g, mod)
+		}
+	}
+}
+
+// Persists the configuration of a channel. Returns false and logs if that failed.
+func (b *Bot) saveChannel(channelID string) bool {
+	if c, ok := b.channels[channelID]; ok {
+		if err := b.storage.SaveChannel(channelID, c); err != nil {
+			log.Printf("Failed to save channel %s: %s", channelID, err)
+			return false
+		}
+	}
+	return true
+}
+
+// How often a player's LastSeenTime is updated while they keep chatting.
+const KeepAliveInterval = time.Minute
+
+func (b *Bot) cleanupPlayers() {
+	b.lock()
+	defer b.unlock()
 	for k, game := range b.games {
 		channel := b.channels[k.Channel]
 		mod := channel.Mods[k.Mod]
+		cutoff := time.Now().Add(time.Duration(-channel.Timeout) * time.Minute)
+		// The waiting list times out even while teams are picked, so that
+		// nobody who left moves up into the next game.
+		var waiting []Player
+		for _, player := range game.Waiting {
+			if player.Value.LastSeenTime.Before(cutoff) {
+				log.Printf("%s timed out on the waiting list", player.Key)
+				b.messenger.Send(k.Channel, fmt.Sprintf("%s was removed from the waiting list of %s because they timed out", mention(player.Key), k.Mod))
+			} else {
+				waiting = append(waiting, player)
+			}
+		}
+		if len(waiting) < len(game.Waiting) {
+			game.Waiting = waiting
+			b.saveGame(k)
+		}
 		if game.IsPickingTeams(mod) {
 			continue
 		}
-		game.mutex.Lock()
-		defer game.mutex.Unlock()
 		var playersToDelete []string
-		for name, player := range game.Players {
-			if player.LastSeenTime.Before(time.Now().Add(time.Duration(-channel.Timeout) * time.Minute)) {
-				log.Printf("%s timed out", name)
-				s.ChannelMessageSend(k.Channel, fmt.Sprintf("%s was removed from %s because they timed out", name, k.Mod))
-				playersToDelete = append(playersToDelete, name)
+		for id, player := range game.Players {
+			if player.LastSeenTime.Before(cutoff) {
+				log.Printf("%s timed out", id)
+				b.messenger.Send(k.Channel, fmt.Sprintf("%s was removed from %s because they timed out", mention(id), k.Mod))
+				playersToDelete = append(playersToDelete, id)
 			}
 		}
 		for _, player := range playersToDelete {
 			delete(game.Players, player)
 		}
+		if len(playersToDelete) > 0 {
+			b.saveGame(k)
+			b.refill(k)
+		}
 	}
 }
 
-func (b *Bot) keepAlive(name string) {
+// Marks a player as seen so that they don't time out. The timeout counts in
+// minutes, so LastSeenTime is only moved, and the game saved, once a minute
+// rather than for every message.
+func (b *Bot) keepAlive(userID string) {
+	b.lock()
+	defer b.unlock()
 	for k, game := range b.games {
 		channel := b.channels[k.Channel]
 		mod := channel.Mods[k.Mod]
-		if game.IsPickingTeams(mod) {
+		player, ok := game.Players[userID]
+		if position := game.waitingPosition(userID); position > 0 {
+			player, ok = game.Waiting[position-1].Value, true
+		} else if game.IsPickingTeams(mod) {
 			continue
 		}
-		for k, player := range game.Players {
-			if k == name {
-				player.LastSeenTime = time.Now()
-			}
+		if ok && time.Since(player.LastSeenTime) >= KeepAliveInterval {
+			player.LastSeenTime = time.Now()
+			b.saveGame(k)
 		}
 	}
 }
-
-func getAnyPlayer(m map[string]*PlayerMetadata) (string, *PlayerMetadata) {
-	for k, v := range m {
-		return k, v
-	}
-	return "", nil
-}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testChannel = "channel"
+
+// testBot runs commands against a bot with memory storage and a recording
+// messenger, in a channel with the mod "ctf".
+type testBot struct {
+	*Bot
+	t         *testing.T
+	messenger *RecordingMessenger
+}
+
+func testUser(i int) User {
+	return User{ID: fmt.Sprintf("1000000000000000%02d", i), Username: fmt.Sprint("player", i), Nick: fmt.Sprint("Player", i)}
+}
+
+// Creates a bot with a `players` player ctf mod and applies `settings`, each
+// a "setting value" pair for .setmod.
+func newTestBot(t *testing.T, players int, settings ...string) *testBot {
+	t.Helper()
+	messenger := &RecordingMessenger{}
+	b, err := NewBot(NewMemoryStorage(), messenger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb := &testBot{b, t, messenger}
+	tb.admin(".enable")
+	tb.admin(fmt.Sprintf(".addmod ctf %d", players))
+	for _, setting := range settings {
+		tb.admin(".setmod ctf " + setting)
+	}
+	return tb
+}
+
+func (tb *testBot) run(user User, command string) {
+	ctx := NewContext(tb.Bot.messenger, testChannel, user)
+	for _, word := range strings.Fields(command) {
+		if strings.HasPrefix(word, "<@") {
+			id := strings.Trim(word, "<@!>")
+			for i := 0; i < 100; i++ {
+				if testUser(i).ID == id {
+					ctx.Mentions = append(ctx.Mentions, testUser(i))
+				}
+			}
+		}
+	}
+	tb.runCommand(ctx, command)
+}
+
+func (tb *testBot) admin(command string) {
+	ctx := NewContext(tb.Bot.messenger, testChannel, User{ID: "1", Username: "admin"})
+	ctx.GuildAdmin = true
+	tb.runCommand(ctx, command)
+}
+
+func (tb *testBot) join(from int, to int) {
+	for i := from; i <= to; i++ {
+		tb.run(testUser(i), ".j ctf")
+	}
+}
+
+func (tb *testBot) game() *Game {
+	tb.lock()
+	defer tb.unlock()
+	return tb.games[GameIdentifier{testChannel, "ctf"}]
+}
+
+func (tb *testBot) mod() *Mod {
+	return tb.channels[testChannel].Mods["ctf"]
+}
+
+// Fails unless a message containing `text` was sent to the channel.
+func (tb *testBot) expectSent(text string) {
+	tb.t.Helper()
+	for _, message := range tb.messenger.Sent(testChannel) {
+		if strings.Contains(message, text) {
+			return
+		}
+	}
+	tb.t.Fatalf("no message contains %q, sent:\n%s", text, strings.Join(tb.messenger.Sent(testChannel), "\n"))
+}
+
+// Lets the captain whose turn it is pick the players with the lowest picking
+// numbers, as many as the turn allows. Returns the turn, e.g. "Red 2".
+func (tb *testBot) pickTurn() string {
+	game := tb.game()
+	color, count := game.PickTurn(tb.mod())
+	var numbers []int
+	for _, player := range game.Players {
+		numbers = append(numbers, player.PickingNumber)
+	}
+	sort.Ints(numbers)
+	var words []string
+	for _, number := range numbers[:count] {
+		words = append(words, strconv.Itoa(number))
+	}
+	captain := User{ID: game.captain(color)}
+	tb.run(captain, ".p "+strings.Join(words, " "))
+	return fmt.Sprintf("%s %d", color, count)
+}
+
+func TestPickingFlow(t *testing.T) {
+	tests := []struct {
+		name      string
+		players   int
+		pickOrder string
+		turns     []string
+	}{
+		{"duel", 2, "snake", nil},
+		{"alternate", 6, "alternate", []string{"Red 1", "Blue 1", "Red 1"}},
+		{"snake", 8, "snake", []string{"Red 1", "Blue 2", "Red 2"}},
+		{"custom", 8, "1-3", []string{"Red 1", "Blue 3", "Red 1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tb := newTestBot(t, test.players, "countdown 0", "pickorder "+test.pickOrder)
+			tb.join(1, test.players)
+			tb.expectSent("**ctf** has filled")
+
+			var turns []string
+			for tb.game().IsPickingTeams(tb.mod()) {
+				if len(turns) > len(test.turns) {
+					t.Fatalf("picking didn't end after %v", turns)
+				}
+				turns = append(turns, tb.pickTurn())
+			}
+			if strings.Join(turns, ", ") != strings.Join(test.turns, ", ") {
+				t.Errorf("turns = %v, want %v", turns, test.turns)
+			}
+
+			tb.expectSent("Teams for **ctf** were selected (match **#1**)")
+			if len(tb.matches) != 1 {
+				t.Fatalf("recorded %d matches, want 1", len(tb.matches))
+			}
+			match := tb.matches[0]
+			if len(match.Red) != test.players/2 || len(match.Blue) != test.players/2 {
+				t.Errorf("teams have %d and %d players, want %d each", len(match.Red), len(match.Blue), test.players/2)
+			}
+			if match.RedCaptain == "" || match.BlueCaptain == "" {
+				t.Errorf("captains = %q and %q, want both set", match.RedCaptain, match.BlueCaptain)
+			}
+			if game := tb.game(); len(game.Players) != 0 {
+				t.Errorf("the next game already has %d players", len(game.Players))
+			}
+		})
+	}
+}
+
+func TestPickErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether red makes the first pick before the command runs.
+		firstPick bool
+		command   func(game *Game) (User, string)
+		want      string
+	}{
+		{"wrong captain", false, func(game *Game) (User, string) {
+			return User{ID: *game.BlueCaptain}, ".p 1"
+		}, "turn to pick"},
+		{"too many", false, func(game *Game) (User, string) {
+			return User{ID: *game.RedCaptain}, ".p 1 2"
+		}, "Red picks 1 now, not 2"},
+		{"twice", true, func(game *Game) (User, string) {
+			number := game.PlayersSortedByJoinTime()[0].Value.PickingNumber
+			return User{ID: *game.BlueCaptain}, fmt.Sprintf(".p %d %d", number, number)
+		}, "can only be picked once"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tb := newTestBot(t, 8, "countdown 0")
+			tb.join(1, 8)
+			if test.firstPick {
+				tb.pickTurn()
+			}
+			tb.run(test.command(tb.game()))
+			if last := tb.messenger.Last(testChannel); !strings.Contains(last, test.want) {
+				t.Errorf("last message = %q, want it to contain %q", last, test.want)
+			}
+		})
+	}
+}
+
+// Run with -race: players join, pick and chat at the same time while pick
+// timers and the cleanup of idle players run.
+func TestConcurrentCommands(t *testing.T) {
+	tb := newTestBot(t, 8, "countdown 0", "picktime 2s")
+	tb.admin(".settimeout 60")
+	stop := time.Now().Add(3 * time.Second)
+	var wg sync.WaitGroup
+	for i := 1; i <= 12; i++ {
+		wg.Add(1)
+		// Captains with an even number never pick, their pick timer does.
+		go func(user User, picks bool) {
+			defer wg.Done()
+			for n := 0; time.Now().Before(stop); n++ {
+				tb.run(user, ".j ctf")
+				tb.keepAlive(user.ID)
+				if picks {
+					tb.run(user, fmt.Sprintf(".p %d", n%6+1))
+				}
+				tb.cleanupPlayers()
+				time.Sleep(10 * time.Millisecond)
+			}
+		}(testUser(i), i%2 == 1)
+	}
+	wg.Wait()
+
+	// Pick timers finish a game nobody picks in within three turns.
+	for wait := time.Now().Add(3 * 2 * time.Second); ; time.Sleep(100 * time.Millisecond) {
+		tb.lock()
+		played := len(tb.matches) > 0
+		tb.unlock()
+		if played || time.Now().After(wait) {
+			break
+		}
+	}
+	tb.lock()
+	defer tb.unlock()
+	if len(tb.matches) == 0 {
+		t.Fatal("no match was played")
+	}
+	for _, match := range tb.matches {
+		players := make(map[string]bool)
+		for _, player := range append(append([]MatchPlayer(nil), match.Red...), match.Blue...) {
+			if players[player.ID] {
+				t.Errorf("%s played twice in match #%d", player.Name, match.ID)
+			}
+			players[player.ID] = true
+		}
+		if len(match.Red) != 4 || len(match.Blue) != 4 {
+			t.Errorf("match #%d has %d and %d players, want 4 each", match.ID, len(match.Red), len(match.Blue))
+		}
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	tb := newTestBot(t, 8)
+	tb.join(1, 1)
+	player := tb.game().Players[testUser(1).ID]
+
+	seen := player.LastSeenTime
+	tb.keepAlive(testUser(1).ID)
+	if !player.LastSeenTime.Equal(seen) {
+		t.Errorf("LastSeenTime moved within %s", KeepAliveInterval)
+	}
+	player.LastSeenTime = time.Now().Add(-2 * KeepAliveInterval)
+	tb.keepAlive(testUser(1).ID)
+	if time.Since(player.LastSeenTime) > time.Second {
+		t.Errorf("LastSeenTime = %s, want now", player.LastSeenTime)
+	}
+}
+
+// answerRecorder is a responder like that of slash commands, which answers
+// when flushed. It notes what the messenger had sent by then.
+type answerRecorder struct {
+	messenger  *RecordingMessenger
+	replies    []string
+	sentBefore []string
+}
+
+func (r *answerRecorder) reply(content string) { r.replies = append(r.replies, content) }
+func (r *answerRecorder) fail(content string)  { r.reply(content) }
+func (r *answerRecorder) deny(reason string)   { r.reply(reason) }
+func (r *answerRecorder) ack()                 {}
+func (r *answerRecorder) flush()               { r.sentBefore = r.messenger.Sent(testChannel) }
+
+func TestCommandAnsweredBeforeMessagesAreSent(t *testing.T) {
+	tb := newTestBot(t, 4)
+	tb.join(1, 3)
+	tb.messenger.Reset()
+
+	answer := &answerRecorder{messenger: tb.messenger}
+	ctx := &Context{ChannelID: testChannel, User: testUser(4), responder: answer}
+	tb.execute(ctx, commandsByName["join"], []string{"ctf"})
+	if len(answer.sentBefore) != 0 {
+		t.Errorf("sent before the command was answered:\n%s", strings.Join(answer.sentBefore, "\n"))
+	}
+	tb.expectSent("has filled")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultCaptainStrategy = "random"
+
+// CaptainStrategy chooses up to `count` captains among the players of a full
+// game who aren't captains yet, most suitable first.
+type CaptainStrategy func(b *Bot, g GameIdentifier, game *Game, count int) []string
+
+var (
+	captainStrategies = map[string]CaptainStrategy{
+		"random":     randomCaptains,
+		"top":        topRatedCaptains,
+		"closest":    closestRatedCaptains,
+		"fair":       leastCaptainedCaptains,
+		"volunteers": volunteerCaptains,
+		"role":       captainRoleCaptains,
+	}
+	// Names of the captain strategies in the order they are documented.
+	captainStrategyNames = []string{"random", "top", "closest", "fair", "volunteers", "role"}
+)
+
+func (mod *Mod) captainStrategyName() string {
+	if _, ok := captainStrategies[mod.CaptainStrategy]; ok {
+		return mod.CaptainStrategy
+	}
+	return DefaultCaptainStrategy
+}
+
+// Picks anyone.
+func randomCaptains(b *Bot, g GameIdentifier, game *Game, count int) []string {
+	players := game.playerIDs()
+	rand.Shuffle(len(players), func(i, j int) {
+		players[i], players[j] = players[j], players[i]
+	})
+	if len(players) > count {
+		players = players[:count]
+	}
+	return players
+}
+
+// Picks the best rated players.
+func topRatedCaptains(b *Bot, g GameIdentifier, game *Game, count int) []string {
+	score := b.scorer(g)
+	players := randomCaptains(b, g, game, len(game.Players))
+	sort.SliceStable(players, func(i, j int) bool {
+		return score(players[i]) > score(players[j])
+	})
+	if len(players) > count {
+		players = players[:count]
+	}
+	return players
+}
+
+// Picks the two players whose ratings are closest, or the player closest to
+// the captain who volunteered.
+func closestRatedCaptains(b *Bot, g GameIdentifier, game *Game, count int) []string {
+	score := b.scorer(g)
+	players := randomCaptains(b, g, game, len(game.Players))
+	captain := *game.RedCaptain
+	if captain == "" {
+		captain = *game.BlueCaptain
+	}
+	if captain != "" {
+		sort.SliceStable(players, func(i, j int) bool {
+			return math.Abs(score(players[i])-score(captain)) < math.Abs(score(players[j])-score(captain))
+		})
+		if len(players) > count {
+			players = players[:count]
+		}
+		return players
+	}
+	var best []string
+	bestDifference := math.Inf(1)
+	for i := range players {
+		for j := i + 1; j < len(players); j++ {
+			if difference := math.Abs(score(players[i]) - score(players[j])); difference < bestDifference {
+				best, bestDifference = []string{players[i], players[j]}, difference
+			}
+		}
+	}
+	return best
+}
+
+// Picks players at random, each weighted by how rarely they were captain.
+func leastCaptainedCaptains(b *Bot, g GameIdentifier, game *Game, count int) []string {
+	records := playerRecords(b.playedMatches(g))
+	players := game.playerIDs()
+	var captains []string
+	for len(captains) < count && len(players) > 0 {
+		weights := make([]float64, len(players))
+		var total float64
+		for i, id := range players {
+			weights[i] = 1
+			if record, ok := records[id]; ok {
+				weights[i] /= float64(1 + record.Captained)
+			}
+			total += weights[i]
+		}
+		chosen := 0
+		for x := rand.Float64() * total; chosen < len(players)-1 && x >= weights[chosen]; chosen++ {
+			x -= weights[chosen]
+		}
+		captains = append(captains, players[chosen])
+		players = append(players[:chosen], players[chosen+1:]...)
+	}
+	return captains
+}
+
+// Picks nobody: only players who volunteer with .captain become captains.
+func volunteerCaptains(b *Bot, g GameIdentifier, game *Game, count int) []string {
+	return nil
+}
+
+// Picks players with the captain role of the mod first and anyone after them.
+func captainRoleCaptains(b *Bot, g GameIdentifier, game *Game, count int) []string {
+	role := b.channels[g.Channel].Mods[g.Mod].CaptainRole
+	players := randomCaptains(b, g, game, len(game.Players))
+	sort.SliceStable(players, func(i, j int) bool {
+		return game.Players[players[i]].hasRole(role) && !game.Players[players[j]].hasRole(role)
+	})
+	if len(players) > count {
+		players = players[:count]
+	}
+	return players
+}
+
+func (player *PlayerMetadata) hasRole(roleID string) bool {
+	for _, id := range player.Roles {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the user IDs of the players who weren't picked yet, sorted.
+func (game *Game) playerIDs() []string {
+	var ids []string
+	for id := range game.Players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Returns a function giving the score of a player in a mod.
+func (b *Bot) scorer(g GameIdentifier) func(userID string) float64 {
+	system := b.channels[g.Channel].Mods[g.Mod].ratingSystem()
+	return func(userID string) float64 {
+		return system.Score(b.rating(g, userID))
+	}
+}
+
+// Chooses the captains that are still missing with the strategy of the mod
+// and starts picking once both are known. If `force` is set, captains the
+// strategy leaves missing, e.g. because it waits for volunteers, are picked at
+// random.
+func (b *Bot) pickRemainingCaptains(g GameIdentifier, mod *Mod, force bool) {
+	game := b.games[g]
+	game.CountdownEnd = time.Time{}
+	strategies := []CaptainStrategy{captainStrategies[mod.captainStrategyName()]}
+	if force {
+		strategies = append(strategies, randomCaptains)
+	}
+	var message []string
+	for _, strategy := range strategies {
+		count := 0
+		for _, captain := range []string{*game.RedCaptain, *game.BlueCaptain} {
+			if captain == "" {
+				count++
+			}
+		}
+		for _, id := range strategy(b, g, game, count) {
+			if captainMessage := game.SetNextCaptainIfPossible(id, game.Players[id]); captainMessage != "" {
+				message = append(message, captainMessage)
+			}
+		}
+	}
+	if !game.IsPickingTeams(mod) {
+		message = append(message, fmt.Sprintf("Waiting for captains of **%s**, volunteer with `%scaptain`", g.Mod, CommandPrefix))
+		b.messenger.Send(g.Channel, strings.Join(message, "\n"))
+		return
+	}
+	b.startPicking(g, message)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Fills a 4 player mod whose captain countdown is still running. Players 1 to
+// 4 are rated 1000, 1400, 1450 and 2000, players 2 and 3 have the captain role.
+func newCaptainsTestBot(t *testing.T, settings ...string) *testBot {
+	tb := newTestBot(t, 4, append([]string{"countdown 10m", "captainrole <@&555>"}, settings...)...)
+	for i, rating := range []string{"1000", "1400", "1450", "2000"} {
+		tb.admin(".setrating <@" + testUser(i+1).ID + "> ctf " + rating)
+		ctx := NewContext(tb.Bot.messenger, testChannel, testUser(i+1))
+		if i == 1 || i == 2 {
+			ctx.Roles = []string{"555"}
+		}
+		tb.runCommand(ctx, ".j ctf")
+	}
+	return tb
+}
+
+func TestCaptainStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		// Numbers of the players expected, in any order. Nil if any will do.
+		want []int
+	}{
+		{"random", nil},
+		{"top", []int{3, 4}},
+		{"closest", []int{2, 3}},
+		{"role", []int{2, 3}},
+		{"volunteers", []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.strategy, func(t *testing.T) {
+			tb := newCaptainsTestBot(t)
+			tb.lock()
+			defer tb.unlock()
+			g := GameIdentifier{testChannel, "ctf"}
+			captains := captainStrategies[test.strategy](tb.Bot, g, tb.games[g], 2)
+			sort.Strings(captains)
+			if test.want == nil {
+				if len(captains) != 2 || captains[0] == captains[1] {
+					t.Errorf("captains = %v, want two players", captains)
+				}
+				return
+			}
+			var want []string
+			for _, i := range test.want {
+				want = append(want, testUser(i).ID)
+			}
+			if strings.Join(captains, " ") != strings.Join(want, " ") {
+				t.Errorf("captains = %v, want %v", captains, want)
+			}
+		})
+	}
+}
+
+func TestClosestToVolunteer(t *testing.T) {
+	tb := newCaptainsTestBot(t, "captains closest")
+	tb.run(testUser(1), ".captain")
+	tb.admin(".frc ctf")
+	game := tb.game()
+	if *game.RedCaptain != testUser(1).ID || *game.BlueCaptain != testUser(2).ID {
+		t.Errorf("captains %s and %s, want Player1 and Player2, rated closest to them", *game.RedCaptain, *game.BlueCaptain)
+	}
+}
+
+func TestForceCaptainsOfVolunteersOnlyMod(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 0", "captains volunteers")
+	tb.join(1, 4)
+	tb.expectSent("Waiting for captains of **ctf**")
+	if tb.game().IsPickingTeams(tb.mod()) {
+		t.Fatal("captains were picked without volunteers")
+	}
+	tb.admin(".frc ctf")
+	if !tb.game().IsPickingTeams(tb.mod()) {
+		t.Errorf("forcing captains didn't pick any")
+	}
+}
+
+func TestFairCaptainsFavourNewCaptains(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 10m")
+	tb.join(1, 4)
+	// Player1 and Player2 captained nine times, the others never.
+	for i := 0; i < 9; i++ {
+		tb.matches = append(tb.matches, &Match{
+			ID: i + 1, Channel: testChannel, Mod: "ctf",
+			RedCaptain: testUser(1).ID, BlueCaptain: testUser(2).ID,
+			Red: []MatchPlayer{{ID: testUser(1).ID}}, Blue: []MatchPlayer{{ID: testUser(2).ID}},
+		})
+	}
+	tb.lock()
+	defer tb.unlock()
+	g := GameIdentifier{testChannel, "ctf"}
+	chosen := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		for _, id := range leastCaptainedCaptains(tb.Bot, g, tb.games[g], 2) {
+			chosen[id]++
+		}
+	}
+	for _, veteran := range []int{1, 2} {
+		for _, newcomer := range []int{3, 4} {
+			if chosen[testUser(veteran).ID] >= chosen[testUser(newcomer).ID] {
+				t.Errorf("Player%d was captain %d times, Player%d %d times", veteran, chosen[testUser(veteran).ID], newcomer, chosen[testUser(newcomer).ID])
+			}
+		}
+	}
+}
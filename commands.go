@@ -0,0 +1,744 @@
+package main
+
+//go:generate go run . -readme README.md
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const CommandPrefix = "."
+
+// Command is a single bot command. Everything the bot knows about a command,
+// from dispatching to documentation, comes from here.
+type Command struct {
+	Name        string
+	Aliases     []string
+	Args        []Arg
+	Permission  PermissionLevel
+	Description string
+	Examples    []string
+	// Whether the bot has to be enabled on the channel for the command to run.
+	RequiresChannel bool
+	Handler         func(b *Bot, ctx *Context, args Args)
+}
+
+// Usage returns how to call the command, e.g. `.join <mod>`.
+func (c *Command) Usage() string {
+	usage := []string{CommandPrefix + c.Name}
+	for _, arg := range c.Args {
+		usage = append(usage, arg.Usage())
+	}
+	return strings.Join(usage, " ")
+}
+
+var (
+	// All commands in the order they are documented.
+	commandList []*Command
+	// Commands by name and alias.
+	commandsByName = make(map[string]*Command)
+)
+
+func init() {
+	for _, command := range defaultCommands() {
+		registerCommand(command)
+	}
+}
+
+func registerCommand(command *Command) {
+	for _, name := range append([]string{command.Name}, command.Aliases...) {
+		if _, ok := commandsByName[name]; ok {
+			log.Fatalf("Command %s is registered twice", name)
+		}
+		commandsByName[name] = command
+	}
+	commandList = append(commandList, command)
+}
+
+func defaultCommands() []*Command {
+	return []*Command{
+		{
+			Name:        "help",
+			Args:        []Arg{{Name: "command", Optional: true}},
+			Description: "Lists the commands you can use, or shows how to use one of them.",
+			Examples:    []string{".help", ".help join"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Help(ctx, args.String(0))
+			},
+		},
+		{
+			Name:        "enable",
+			Description: "Enables the bot on this channel.",
+			Permission:  PermissionAdmin,
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Enable(ctx)
+			},
+		},
+		{
+			Name:            "disable",
+			Description:     "Disables the bot on this channel and drops all of its games.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Disable(ctx)
+			},
+		},
+		{
+			Name:            "addmod",
+			Args:            []Arg{{Name: "mod"}, {Name: "players", Type: ArgInt}},
+			Description:     "Adds a mod with the given number of players.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".addmod ctf 8"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Addmod(ctx, args.String(0), args.Int(1))
+			},
+		},
+		{
+			Name:            "settimeout",
+			Args:            []Arg{{Name: "minutes", Type: ArgInt}},
+			Description:     "Sets after how many minutes of inactivity players are removed.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".settimeout 30"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Settimeout(ctx, args.Int(0))
+			},
+		},
+		{
+			Name: "perm",
+			Args: []Arg{
+				{Name: "action", Choices: []string{"add", "remove", "list"}},
+				{Name: "target", Type: ArgMentionable, Optional: true},
+				{Name: "level", Choices: permissionLevelNames, Optional: true},
+			},
+			Description:     "Grants a role or user a permission level on this channel, takes it away or lists who has one.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".perm add @Admins admin", ".perm add @alice referee", ".perm remove @alice", ".perm list"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				switch args.String(0) {
+				case "add":
+					if !args.Has(1) || !args.Has(2) {
+						ctx.Fail(fmt.Sprintf("Usage: `%sperm add <@role|@user> <level>`", CommandPrefix))
+						return
+					}
+					b.Permadd(ctx, args.Mentionable(1), parsePermissionLevel(args.String(2)))
+				case "remove":
+					if !args.Has(1) || args.Has(2) {
+						ctx.Fail(fmt.Sprintf("Usage: `%sperm remove <@role|@user>`", CommandPrefix))
+						return
+					}
+					b.Permremove(ctx, args.Mentionable(1))
+				default:
+					b.Permlist(ctx)
+				}
+			},
+		},
+		{
+			Name:            "gettimeout",
+			Description:     "Shows the inactivity timeout.",
+			RequiresChannel: true,
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Gettimeout(ctx)
+			},
+		},
+		{
+			Name:            "listall",
+			Aliases:         []string{"lsa"},
+			Description:     "Shows all active mods and added players.",
+			RequiresChannel: true,
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.ListAll(ctx)
+			},
+		},
+		{
+			Name:            "list",
+			Aliases:         []string{"ls"},
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Shows players who joined a particular mod.",
+			RequiresChannel: true,
+			Examples:        []string{".ls ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.List(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "join",
+			Aliases:         []string{"j"},
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Joins a particular mod.",
+			RequiresChannel: true,
+			Examples:        []string{".join ctf", ".j ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Join(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "joinpm",
+			Aliases:         []string{"jp"},
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Joins a particular mod and asks to be notified when it fills.",
+			RequiresChannel: true,
+			Examples:        []string{".jp ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Joinpm(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "pm",
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Asks to be notified when a mod you joined fills.",
+			RequiresChannel: true,
+			Examples:        []string{".pm ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Pm(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "addplayer",
+			Args:            []Arg{{Name: "mod", Type: ArgMod}, {Name: "player", Type: ArgPlayer, Variadic: true}},
+			Description:     "Adds players to a mod. Adding anyone but yourself requires moderator.",
+			RequiresChannel: true,
+			Examples:        []string{".addplayer ctf @alice @bob"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Addplayer(ctx, args.String(0), args.Users(1)...)
+			},
+		},
+		{
+			Name:            "leave",
+			Aliases:         []string{"l"},
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Leaves a particular mod.",
+			RequiresChannel: true,
+			Examples:        []string{".l ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Leave(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "leaveall",
+			Aliases:         []string{"lva"},
+			Description:     "Leaves all mods.",
+			RequiresChannel: true,
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Leaveall(ctx)
+			},
+		},
+		{
+			Name:            "ready",
+			Description:     "Confirms you are ready when a filled mod checks who is.",
+			RequiresChannel: true,
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Ready(ctx)
+			},
+		},
+		{
+			Name:            "captain",
+			Description:     "Volunteers as captain of a filled mod.",
+			RequiresChannel: true,
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Captain(ctx)
+			},
+		},
+		{
+			Name:            "forcerandomcaptains",
+			Aliases:         []string{"frc"},
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Picks the remaining captains right away, the way the mod chooses captains, or at random if it only takes volunteers.",
+			Permission:      PermissionModerator,
+			RequiresChannel: true,
+			Examples:        []string{".frc ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Forcerandomcaptains(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "pick",
+			Aliases:         []string{"p"},
+			Args:            []Arg{{Name: "number", Type: ArgInt, Variadic: true, Suggest: (*Bot).suggestPickingNumbers}},
+			Description:     "Picks players by their picking number, as many as your turn allows.",
+			RequiresChannel: true,
+			Examples:        []string{".p 3", ".p 3 5"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Pick(ctx, args.Ints(0)...)
+			},
+		},
+		{
+			Name:            "pn",
+			Args:            []Arg{{Name: "player", Type: ArgPlayer, Variadic: true}},
+			Description:     "Picks players by name.",
+			RequiresChannel: true,
+			Examples:        []string{".pn alice"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Pn(ctx, args.Users(0)...)
+			},
+		},
+		{
+			Name:            "pickname",
+			Args:            []Arg{{Name: "mod", Type: ArgMod}, {Name: "player", Type: ArgPlayer, Variadic: true}},
+			Description:     "Picks players by name when several mods are picking at once. Referees can pick for either team.",
+			RequiresChannel: true,
+			Examples:        []string{".pickname ctf alice bob"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Pickname(ctx, args.String(0), args.Users(1)...)
+			},
+		},
+		{
+			Name:            "unpick",
+			Args:            []Arg{{Name: "mod", Type: ArgMod, Optional: true}},
+			Description:     "Undoes the last pick. Only the captain who made it and admins can. The mod can be left out if only one is picking.",
+			RequiresChannel: true,
+			Examples:        []string{".unpick", ".unpick ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Unpick(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "teams",
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Shows the teams while picking is in progress, or those of the last match.",
+			RequiresChannel: true,
+			Examples:        []string{".teams ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Teams(ctx, args.String(0))
+			},
+		},
+		{
+			Name: "last",
+			Args: []Arg{
+				{Name: "mod", Optional: true, Suggest: (*Bot).suggestMods},
+				{Name: "count", Type: ArgInt, Optional: true},
+			},
+			Description:     fmt.Sprintf("Shows the last match on this channel, or the last few of them (at most %d).", MaxLastMatches),
+			RequiresChannel: true,
+			Examples:        []string{".last", ".last ctf", ".last 3", ".last ctf 3"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				modName, count := args.String(0), 1
+				if n, err := strconv.Atoi(modName); err == nil && !args.Has(1) {
+					modName, count = "", n
+				} else if args.Has(1) {
+					count = args.Int(1)
+				}
+				if modName == "" || b.checkMod(ctx, modName) {
+					b.Last(ctx, modName, 0, count)
+				}
+			},
+		},
+		{
+			Name:            "lastt",
+			Args:            []Arg{{Name: "mod", Type: ArgMod, Optional: true}},
+			Description:     "Shows the match before the last one.",
+			RequiresChannel: true,
+			Examples:        []string{".lastt", ".lastt ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Last(ctx, args.String(0), 1, 1)
+			},
+		},
+		{
+			Name: "report",
+			Args: []Arg{
+				{Name: "match", Type: ArgMatch, Suggest: (*Bot).suggestMatches},
+				{Name: "winner", Choices: outcomeNames},
+				{Name: "score", Type: ArgScore, Optional: true},
+			},
+			Description:     "Reports who won a match, given by number or as the last match of a mod. Captains need the other captain to confirm, referees don't.",
+			RequiresChannel: true,
+			Examples:        []string{".report 12 red", ".report ctf blue 2-3", ".report #12 draw"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Report(ctx, args.String(0), parseOutcome(args.String(1)), args.String(2))
+			},
+		},
+		{
+			Name:            "confirm",
+			Args:            []Arg{{Name: "match", Type: ArgMatch, Suggest: (*Bot).suggestMatches}},
+			Description:     "Confirms the result the other captain reported.",
+			RequiresChannel: true,
+			Examples:        []string{".confirm 12"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Confirm(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "contest",
+			Args:            []Arg{{Name: "match", Type: ArgMatch, Suggest: (*Bot).suggestMatches}},
+			Description:     "Disputes the result the other captain reported.",
+			RequiresChannel: true,
+			Examples:        []string{".contest 12"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Contest(ctx, args.String(0))
+			},
+		},
+		{
+			Name: "setresult",
+			Args: []Arg{
+				{Name: "match", Type: ArgMatch, Suggest: (*Bot).suggestMatches},
+				{Name: "winner", Choices: outcomeNames},
+				{Name: "score", Type: ArgScore, Optional: true},
+			},
+			Description:     "Sets the result of a match, overriding whatever was reported.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".setresult 12 blue 1-2"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Setresult(ctx, args.String(0), parseOutcome(args.String(1)), args.String(2))
+			},
+		},
+		{
+			Name:            "voidmatch",
+			Args:            []Arg{{Name: "match", Type: ArgMatch, Suggest: (*Bot).suggestMatches}},
+			Description:     "Makes a match not count for ratings and stats.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".voidmatch 12"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Voidmatch(ctx, args.String(0))
+			},
+		},
+		{
+			Name: "sub",
+			Args: []Arg{
+				{Name: "match", Type: ArgMatch, Suggest: (*Bot).suggestMatches},
+				{Name: "out", Type: ArgPlayer},
+				{Name: "in", Type: ArgPlayer},
+			},
+			Description:     "Replaces a player of a match by someone else. Captains can substitute their own players until the result is confirmed, referees anyone.",
+			RequiresChannel: true,
+			Examples:        []string{".sub 12 alice @bob", ".sub ctf @alice @bob"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Sub(ctx, args.String(0), args.User(1), args.User(2))
+			},
+		},
+		{
+			Name: "swap",
+			Args: []Arg{
+				{Name: "match", Type: ArgMatch, Suggest: (*Bot).suggestMatches},
+				{Name: "player", Type: ArgPlayer},
+				{Name: "other", Type: ArgPlayer},
+			},
+			Description:     "Moves two players of a match to each other's team.",
+			Permission:      PermissionReferee,
+			RequiresChannel: true,
+			Examples:        []string{".swap 12 alice bob"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Swap(ctx, args.String(0), args.User(1), args.User(2))
+			},
+		},
+		{
+			Name:            "setrating",
+			Args:            []Arg{{Name: "player", Type: ArgPlayer}, {Name: "mod", Type: ArgMod}, {Name: "rating", Type: ArgInt}},
+			Description:     "Sets the rating of a player in a mod. Their matches from now on are rated starting from it.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".setrating @alice ctf 1700", ".setrating alice ctf 1400"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Setrating(ctx, args.User(0), args.String(1), args.Int(2))
+			},
+		},
+		{
+			Name:            "resetratings",
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Starts the ratings of a mod over. Earlier matches stay in the history but don't count.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".resetratings ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Resetratings(ctx, args.String(0))
+			},
+		},
+		{
+			Name:            "recomputeratings",
+			Args:            []Arg{{Name: "mod", Type: ArgMod, Optional: true}},
+			Description:     "Rates all matches of a mod, or of every mod on this channel, again.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".recomputeratings", ".recomputeratings ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Recomputeratings(ctx, args.String(0))
+			},
+		},
+		{
+			Name:    "leaderboard",
+			Aliases: []string{"lb"},
+			Args: []Arg{
+				{Name: "mod", Optional: true, Suggest: (*Bot).suggestMods},
+				{Name: "page", Type: ArgInt, Optional: true},
+			},
+			Description:     "Ranks the players of a mod by rating. The mod can be left out if there is only one.",
+			RequiresChannel: true,
+			Examples:        []string{".leaderboard ctf", ".lb ctf 2", ".lb 2"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				modName, page := args.String(0), 1
+				if n, err := strconv.Atoi(modName); err == nil && !args.Has(1) {
+					modName, page = "", n
+				} else if args.Has(1) {
+					page = args.Int(1)
+				}
+				if modName = b.modOrOnly(ctx, modName); modName != "" {
+					b.Leaderboard(ctx, modName, page)
+				}
+			},
+		},
+		{
+			Name: "stats",
+			Args: []Arg{
+				{Name: "player", Type: ArgPlayer, Optional: true},
+				{Name: "mod", Optional: true, Suggest: (*Bot).suggestMods},
+			},
+			Description:     "Shows the games, results, rating, picks and teammates of a player, yourself by default, in one or every mod.",
+			RequiresChannel: true,
+			Examples:        []string{".stats", ".stats ctf", ".stats @Player", ".stats @Player ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				player, modName := ctx.User, args.String(1)
+				if args.Has(0) {
+					player = args.User(0)
+					if _, ok := b.channels[ctx.ChannelID].Mods[player.Username]; ok && player.ID == "" && !args.Has(1) {
+						player, modName = ctx.User, player.Username
+					}
+				}
+				if modName == "" || b.checkMod(ctx, modName) {
+					b.Stats(ctx, player, modName)
+				}
+			},
+		},
+		{
+			Name: "setmod",
+			Args: []Arg{
+				{Name: "mod", Type: ArgMod},
+				{Name: "setting", Choices: modSettingNames, Optional: true},
+				{Name: "value", Optional: true},
+			},
+			Description:     "Changes a setting of a mod. Shows the setting, or all of them, if the rest is left out.",
+			Permission:      PermissionAdmin,
+			RequiresChannel: true,
+			Examples:        []string{".setmod ctf", ".setmod ctf mingames 10", ".setmod ctf rating glicko2"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Setmod(ctx, args.String(0), args.String(1), args.String(2))
+			},
+		},
+		{
+			Name:            "reset",
+			Args:            []Arg{{Name: "mod", Type: ArgMod}},
+			Description:     "Undoes all picks and captains of a mod.",
+			Permission:      PermissionModerator,
+			RequiresChannel: true,
+			Examples:        []string{".reset ctf"},
+			Handler: func(b *Bot, ctx *Context, args Args) {
+				b.Reset(ctx, args.String(0))
+			},
+		},
+	}
+}
+
+// Splits a message into a lower case command name and its arguments. Returns
+// an empty name if the message isn't a command.
+func parseCommand(content string) (string, []string) {
+	if !strings.HasPrefix(content, CommandPrefix) {
+		return "", nil
+	}
+	fields := strings.Fields(strings.TrimPrefix(content, CommandPrefix))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(fields[0]), parseArguments(content)
+}
+
+// Whether the author of the command may run `command` on its channel.
+func (b *Bot) canRun(ctx *Context, command *Command) bool {
+	if _, ok := b.channels[ctx.ChannelID]; command.RequiresChannel && !ok {
+		return false
+	}
+	return b.hasPermission(ctx, command.Permission)
+}
+
+// Whether `modName` exists on the channel. Tells the user if it doesn't.
+func (b *Bot) checkMod(ctx *Context, modName string) bool {
+	parser := argParser{channel: b.channels[ctx.ChannelID]}
+	if parser.channel.Mods[modName] == nil {
+		ctx.Fail(fmt.Sprintf("Unknown mod `%s`. Mods on this channel: %s", modName, parser.modNames()))
+		return false
+	}
+	return true
+}
+
+// Returns `modName` if it exists, or the only mod of the channel if it's
+// empty. Tells the user and returns an empty string otherwise.
+func (b *Bot) modOrOnly(ctx *Context, modName string) string {
+	if modName != "" {
+		if !b.checkMod(ctx, modName) {
+			return ""
+		}
+		return modName
+	}
+	c := b.channels[ctx.ChannelID]
+	if len(c.Mods) != 1 {
+		parser := argParser{channel: c}
+		ctx.Fail(fmt.Sprintf("Which mod? Mods on this channel: %s", parser.modNames()))
+		return ""
+	}
+	for name := range c.Mods {
+		modName = name
+	}
+	return modName
+}
+
+// Runs the command in `content`, if any.
+func (b *Bot) runCommand(ctx *Context, content string) {
+	name, words := parseCommand(content)
+	if command, ok := commandsByName[name]; ok {
+		b.execute(ctx, command, words)
+	}
+}
+
+// Checks that the user may run `command`, parses the words following it and
+// runs it.
+func (b *Bot) execute(ctx *Context, command *Command, words []string) {
+	b.dispatch(ctx, command, func(parser *argParser) (Args, error) {
+		return parser.parse(command.Args, words)
+	})
+}
+
+// Like execute, for slash commands whose options are given by argument name.
+func (b *Bot) executeOptions(ctx *Context, command *Command, options map[string]string) {
+	b.dispatch(ctx, command, func(parser *argParser) (Args, error) {
+		return parser.parseOptions(command.Args, options)
+	})
+}
+
+// Runs `command` while holding the mutex, then answers it before sending what
+// else it queued: slash commands have to be answered within three seconds,
+// however long the rest takes to send.
+func (b *Bot) dispatch(ctx *Context, command *Command, parse func(parser *argParser) (Args, error)) {
+	b.run(ctx, command, parse)
+	ctx.responder.flush()
+	b.outbox.flush()
+}
+
+// Checks, parses and runs `command` while holding the mutex.
+func (b *Bot) run(ctx *Context, command *Command, parse func(parser *argParser) (Args, error)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.channels[ctx.ChannelID]; command.RequiresChannel && !ok {
+		ctx.Deny("Pugbot is not enabled on this channel")
+		return
+	}
+	if !b.canRun(ctx, command) {
+		log.Printf("%s tried running %s but is not allowed to", ctx.User.Username, command.Name)
+		ctx.Deny(fmt.Sprintf("You are not allowed to use %s%s", CommandPrefix, command.Name))
+		return
+	}
+	args, err := parse(&argParser{b.channels[ctx.ChannelID], ctx.Mentions})
+	if err != nil {
+		log.Printf("Invalid arguments for %s: %s", command.Name, err)
+		ctx.Fail(fmt.Sprintf("%s\nUsage: `%s`", err, command.Usage()))
+		return
+	}
+	log.Printf("Running command %s %v", command.Name, args)
+	command.Handler(b, ctx, args)
+}
+
+// Shows the commands the author is allowed to use, or details about `name`.
+func (b *Bot) Help(ctx *Context, name string) {
+	if name == "" {
+		lines := []string{fmt.Sprintf("**Commands** (use `%shelp <command>` for details)", CommandPrefix)}
+		for _, command := range commandList {
+			if b.canRun(ctx, command) {
+				lines = append(lines, fmt.Sprintf("`%s` %s", command.Usage(), command.Description))
+			}
+		}
+		ctx.ReplyLines(lines)
+		return
+	}
+
+	command, ok := commandsByName[strings.ToLower(strings.TrimPrefix(name, CommandPrefix))]
+	if !ok {
+		ctx.Fail(fmt.Sprintf("Unknown command `%s`. Use `%shelp` to list commands.", name, CommandPrefix))
+		return
+	}
+	ctx.Reply(command.Help())
+}
+
+// Help renders the usage, aliases, arguments and examples of the command.
+func (c *Command) Help() string {
+	var help strings.Builder
+	fmt.Fprintf(&help, "**%s**\n%s\n", c.Usage(), c.Description)
+	if len(c.Aliases) > 0 {
+		var aliases []string
+		for _, alias := range c.Aliases {
+			aliases = append(aliases, fmt.Sprintf("`%s%s`", CommandPrefix, alias))
+		}
+		fmt.Fprintf(&help, "Aliases: %s\n", strings.Join(aliases, " "))
+	}
+	if len(c.Args) > 0 {
+		help.WriteString("Arguments:\n")
+		for _, arg := range c.Args {
+			var notes []string
+			if arg.Optional {
+				notes = append(notes, "optional")
+			}
+			if arg.Variadic {
+				notes = append(notes, "one or more")
+			}
+			note := ""
+			if len(notes) > 0 {
+				note = fmt.Sprintf(" (%s)", strings.Join(notes, ", "))
+			}
+			fmt.Fprintf(&help, ":small_orange_diamond: `%s` %s%s\n", arg.Name, arg.Describe(), note)
+		}
+	}
+	if c.Permission != PermissionEveryone {
+		fmt.Fprintf(&help, "Requires %s.\n", c.Permission)
+	}
+	if len(c.Examples) > 0 {
+		var examples []string
+		for _, example := range c.Examples {
+			examples = append(examples, fmt.Sprintf("`%s`", example))
+		}
+		fmt.Fprintf(&help, "Examples: %s\n", strings.Join(examples, " "))
+	}
+	return help.String()
+}
+
+// Renders the command reference as a markdown table.
+func commandTable() string {
+	var table strings.Builder
+	table.WriteString("| Command | Aliases | Description |\n")
+	table.WriteString("|---------|---------|-------------|\n")
+	for _, command := range commandList {
+		var aliases []string
+		for _, alias := range command.Aliases {
+			aliases = append(aliases, fmt.Sprintf("`%s%s`", CommandPrefix, alias))
+		}
+		description := command.Description
+		if command.Permission != PermissionEveryone {
+			description += fmt.Sprintf(" Requires %s.", command.Permission)
+		}
+		fmt.Fprintf(&table, "| `%s` | %s | %s |\n", command.Usage(), strings.Join(aliases, " "), description)
+	}
+	return table.String()
+}
+
+const (
+	readmeCommandsStart = "<!-- commands -->\n"
+	readmeCommandsEnd   = "<!-- /commands -->"
+)
+
+// Replaces the command table in the README at `path` with one generated from
+// the registered commands.
+func updateReadme(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	readme := string(data)
+	start := strings.Index(readme, readmeCommandsStart)
+	end := strings.Index(readme, readmeCommandsEnd)
+	if start < 0 || end < start {
+		return fmt.Errorf("%s has no %q and %q markers", path, readmeCommandsStart, readmeCommandsEnd)
+	}
+	readme = readme[:start+len(readmeCommandsStart)] + commandTable() + readme[end:]
+	return ioutil.WriteFile(path, []byte(readme), 0644)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord rejects messages longer than this.
+const MaxMessageLength = 2000
+
+type User struct {
+	ID       string
+	Username string
+	// Nickname on the guild, if any.
+	Nick string
+}
+
+// DisplayName is the name shown for the user on the guild.
+func (u User) DisplayName() string {
+	if u.Nick != "" {
+		return u.Nick
+	}
+	return u.Username
+}
+
+func mention(userID string) string {
+	return "<@" + userID + ">"
+}
+
+// Mentionable is a user or a role, e.g. someone permissions are granted to.
+type Mentionable struct {
+	ID   string
+	Role bool
+}
+
+func (m Mentionable) String() string {
+	if m.Role {
+		return "<@&" + m.ID + ">"
+	}
+	return mention(m.ID)
+}
+
+// Discord permissions that make a user an admin of the bot everywhere on the guild.
+const guildAdminPermissions = discordgo.PermissionAdministrator | discordgo.PermissionManageServer
+
+// Context describes a single command: who sent it, where, and how to answer.
+type Context struct {
+	ChannelID string
+	GuildID   string
+	// The message that contained the command.
+	MessageID string
+	User      User
+	// IDs of the roles the user has on the guild.
+	Roles []string
+	// Whether the user administers the guild.
+	GuildAdmin bool
+	Mentions   []User
+	responder  responder
+}
+
+// responder answers a command. Text commands are answered in the channel,
+// slash commands through their interaction.
+type responder interface {
+	reply(content string)
+	fail(content string)
+	deny(reason string)
+	ack()
+	// Sends the answers that weren't sent right away.
+	flush()
+}
+
+// NewContext creates the context of a text command sent to `channelID`.
+func NewContext(messenger Messenger, channelID string, user User) *Context {
+	ctx := &Context{ChannelID: channelID, User: user}
+	ctx.responder = &messageResponder{messenger, ctx}
+	return ctx
+}
+
+// Builds the context of a Discord message.
+func newMessageContext(s *discordgo.Session, m *discordgo.MessageCreate, messenger Messenger) *Context {
+	ctx := NewContext(messenger, m.ChannelID, User{ID: m.Author.ID, Username: m.Author.Username})
+	ctx.GuildID = m.GuildID
+	ctx.MessageID = m.ID
+	for _, user := range m.Mentions {
+		ctx.Mentions = append(ctx.Mentions, guildUser(s, m.GuildID, user))
+	}
+	if m.Member != nil {
+		ctx.User.Nick = m.Member.Nick
+		ctx.Roles = m.Member.Roles
+		if permissions, err := s.State.MessagePermissions(m.Message); err == nil {
+			ctx.GuildAdmin = permissions&guildAdminPermissions != 0
+		}
+	}
+	return ctx
+}
+
+// Looks up the nickname of `user` on a guild, if it's known.
+func guildUser(s *discordgo.Session, guildID string, user *discordgo.User) User {
+	u := User{ID: user.ID, Username: user.Username}
+	if member, err := s.State.Member(guildID, user.ID); err == nil {
+		u.Nick = member.Nick
+	}
+	return u
+}
+
+// Reply answers the command.
+func (ctx *Context) Reply(content string) {
+	ctx.responder.reply(content)
+}
+
+// ReplyLines answers with `lines` joined by newlines, split over as few
+// messages as Discord allows.
+func (ctx *Context) ReplyLines(lines []string) {
+	for _, message := range joinLines(lines, MaxMessageLength) {
+		ctx.Reply(message)
+	}
+}
+
+// Fail tells the user why their command didn't work.
+func (ctx *Context) Fail(content string) {
+	ctx.responder.fail(content)
+}
+
+// Deny refuses to run a command. Text commands stay silent, so that the bot
+// doesn't talk in channels it isn't used in, but slash commands always need an
+// answer.
+func (ctx *Context) Deny(reason string) {
+	ctx.responder.deny(reason)
+}
+
+// Ack confirms that the command worked without saying anything.
+func (ctx *Context) Ack() {
+	ctx.responder.ack()
+}
+
+type messageResponder struct {
+	messenger Messenger
+	ctx       *Context
+}
+
+func (r *messageResponder) reply(content string) {
+	r.messenger.Send(r.ctx.ChannelID, content)
+}
+
+func (r *messageResponder) fail(content string) {
+	r.reply(content)
+}
+
+func (r *messageResponder) deny(reason string) {
+	log.Printf("Ignoring command from %s: %s", r.ctx.User.Username, reason)
+}
+
+func (r *messageResponder) ack() {
+	r.messenger.React(r.ctx.ChannelID, r.ctx.MessageID, "✅")
+}
+
+// Text commands are answered through the messenger, which sends on its own.
+func (r *messageResponder) flush() {}
+
+// Joins `lines` with newlines into messages of at most `limit` characters.
+func joinLines(lines []string, limit int) []string {
+	var messages []string
+	var message strings.Builder
+	for _, line := range lines {
+		if message.Len() > 0 && message.Len()+len(line)+1 > limit {
+			messages = append(messages, message.String())
+			message.Reset()
+		}
+		if message.Len() > 0 {
+			message.WriteString("\n")
+		}
+		message.WriteString(line)
+	}
+	if message.Len() > 0 {
+		messages = append(messages, message.String())
+	}
+	return messages
+}
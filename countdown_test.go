@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountdownSetting(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", "countdown: 20s"},
+		{"0", "countdown: 0s"},
+		{"45s", "countdown: 45s"},
+		{"1500ms", "countdown: 2s"},
+		{"-1s", "countdown should be a duration"},
+		{"11m", "at most 10 minutes"},
+		{"soon", "countdown should be a duration"},
+	}
+	for _, test := range tests {
+		tb := newTestBot(t, 4)
+		tb.admin(strings.TrimSpace(".setmod ctf countdown " + test.value))
+		if last := tb.messenger.Last(testChannel); !strings.Contains(last, test.want) {
+			t.Errorf("countdown %q: last message = %q, want it to contain %q", test.value, last, test.want)
+		}
+	}
+}
+
+func TestCountdownUpdateSeconds(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     int
+	}{
+		{2 * time.Second, 1},
+		{CaptainCountdown, 5},
+		{time.Minute, 15},
+		{10 * time.Minute, 150},
+	}
+	for _, test := range tests {
+		if got := countdownUpdateSeconds(test.duration); got != test.want {
+			t.Errorf("countdownUpdateSeconds(%s) = %d, want %d", test.duration, got, test.want)
+		}
+	}
+}
+
+func TestCountdownPicksCaptains(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 1s")
+	tb.join(1, 4)
+	tb.run(testUser(3), ".captain")
+	// The countdown changes the game on its own goroutine.
+	captains := func() (bool, string) {
+		tb.lock()
+		defer tb.unlock()
+		game := tb.games[GameIdentifier{testChannel, "ctf"}]
+		return game.IsPickingTeams(tb.mod()), *game.RedCaptain
+	}
+	if picking, red := captains(); picking || red != testUser(3).ID {
+		t.Fatalf("Player3 should be the only captain while the countdown runs")
+	}
+	for wait := time.Now().Add(3 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		picking, red := captains()
+		if picking {
+			if red != testUser(3).ID {
+				t.Errorf("red captain %s, want the volunteer %s", red, testUser(3).ID)
+			}
+			return
+		}
+		if time.Now().After(wait) {
+			t.Fatal("no captains were picked after the countdown")
+		}
+	}
+}
+
+// Starts a new bot on the storage of `tb`, as after a restart, and resumes
+// its countdowns.
+func (tb *testBot) restart() *testBot {
+	tb.t.Helper()
+	messenger := &RecordingMessenger{}
+	b, err := NewBot(tb.storage, messenger)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	restarted := &testBot{b, tb.t, messenger}
+	restarted.lock()
+	restarted.resumeCountdowns()
+	restarted.unlock()
+	return restarted
+}
+
+func TestRestartResumesCountdown(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 10m")
+	tb.join(1, 4)
+	restarted := tb.restart()
+	restarted.expectSent("Captains will be selected")
+	for _, message := range restarted.messenger.Sent(testChannel) {
+		if strings.Contains(message, "has filled:") {
+			t.Errorf("fill announced again: %q", message)
+		}
+	}
+}
+
+func TestRestartWhileWaitingForVolunteers(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 0", "captains volunteers")
+	tb.join(1, 4)
+	tb.expectSent("Waiting for captains of **ctf**")
+	restarted := tb.restart()
+	if sent := restarted.messenger.Sent(testChannel); len(sent) > 0 {
+		t.Errorf("sent after restart:\n%s", strings.Join(sent, "\n"))
+	}
+	restarted.run(testUser(1), ".captain")
+	restarted.run(testUser(2), ".captain")
+	if !restarted.game().IsPickingTeams(restarted.mod()) {
+		t.Error("volunteers didn't become captains after the restart")
+	}
+}
@@ -3,23 +3,76 @@ package main
 import (
 	"fmt"
 	"log"
-	"math/rand"
-	"reflect"
 	"sort"
 	"strings"
-	"sync"
 	"time"
-
-	"github.com/bwmarrin/discordgo"
 )
 
+// How long players have to volunteer as captain before captains are picked,
+// unless the mod sets its own countdown.
+const CaptainCountdown = 20 * time.Second
+
+// Longest countdown a mod can set.
+const MaxCaptainCountdown = 10 * time.Minute
+
 type Game struct {
 	Players     map[string]*PlayerMetadata
 	Red         map[string]*PlayerMetadata
 	Blue        map[string]*PlayerMetadata
 	RedCaptain  *string
 	BlueCaptain *string
-	mutex       *sync.Mutex
+	// When captains get picked automatically. Zero if no countdown is running.
+	CountdownEnd time.Time
+	// When players who aren't ready are removed. Zero if there is no ready
+	// check running.
+	ReadyDeadline time.Time
+	// The message players react to when they are ready. Only set once it was
+	// sent, until then the bot knows it by its queued ID alone.
+	ReadyMessageID       string
+	queuedReadyMessageID string
+	// When the captain whose turn it is runs out of time. Zero if there is no
+	// pick timer.
+	PickDeadline time.Time
+	// Picks so far, oldest first.
+	Picks []Pick
+	// Players who joined while the game was full, first to move up first.
+	Waiting []Player
+	// When the game last filled up and when both captains were known.
+	FilledTime   time.Time
+	CaptainsTime time.Time
+}
+
+// Pick is a player picked for a team, kept so that the pick can be undone.
+type Pick struct {
+	Player string
+	Team   TeamColor
+	// User ID of the captain whose turn it was.
+	Captain string
+}
+
+func NewGame() *Game {
+	game := &Game{}
+	game.initialize()
+	return game
+}
+
+// Fills in everything that isn't persisted, e.g. after loading a game from storage.
+func (game *Game) initialize() {
+	if game.Players == nil {
+		game.Players = make(map[string]*PlayerMetadata)
+	}
+	if game.Red == nil {
+		game.Red = make(map[string]*PlayerMetadata)
+	}
+	if game.Blue == nil {
+		game.Blue = make(map[string]*PlayerMetadata)
+	}
+	if game.RedCaptain == nil {
+		game.RedCaptain = new(string)
+	}
+	if game.BlueCaptain == nil {
+		game.BlueCaptain = new(string)
+	}
 }
 
 func (game *Game) IsPickingTeams(mod *Mod) bool {
@@ -30,8 +83,8 @@ func (game *Game) IsFull(mod *Mod) bool {
 	return len(game.Players)+len(game.Red)+len(game.Blue) == mod.MaxPlayers
 }
 
-func (game *Game) HasPlayer(playerName string) bool {
-	if _, ok := game.Players[playerName]; !ok {
+func (game *Game) HasPlayer(userID string) bool {
+	if _, ok := game.Players[userID]; !ok {
 		return false
 	}
 
@@ -42,20 +95,46 @@ func (game *Game) PickedPlayerCount() int {
 	return len(game.Red) + len(game.Blue)
 }
 
-func (game *Game) AddPlayer(playerName string) {
-	if _, ok := game.Players[playerName]; !ok {
-		log.Printf(fmt.Sprintf("Adding player %s to %p", playerName, game))
-		game.Players[playerName] = &PlayerMetadata{JoinTime: time.Now(), LastSeenTime: time.Now()}
+func (game *Game) AddPlayer(user User) {
+	if _, ok := game.Players[user.ID]; !ok {
+		log.Printf("Adding player %s (%s) to %p", user.DisplayName(), user.ID, game)
+		game.Players[user.ID] = &PlayerMetadata{Name: user.DisplayName(), JoinTime: time.Now(), LastSeenTime: time.Now()}
 	}
 }
 
+// Finds a player who hasn't been picked yet by user ID or, failing that, by
+// display name. Returns an empty string if there is no such player.
+func (game *Game) FindPlayer(user User) string {
+	if game.HasPlayer(user.ID) {
+		return user.ID
+	}
+	for id, player := range game.Players {
+		if strings.EqualFold(player.Name, user.DisplayName()) {
+			return id
+		}
+	}
+	return ""
+}
+
+// Mentions everyone who joined the game, including captains and picked players.
+func (game *Game) MentionAll() string {
+	var mentions []string
+	for _, team := range []map[string]*PlayerMetadata{game.Red, game.Blue, game.Players} {
+		for id := range team {
+			mentions = append(mentions, mention(id))
+		}
+	}
+	sort.Strings(mentions)
+	return strings.Join(mentions, " ")
+}
+
 func (game *Game) BuildPlayerList() string {
 	sortedPlayers := game.PlayersSortedByJoinTime()
 	var sortedPlayerNames []string
 	for _, player := range sortedPlayers {
-		message := player.Key
+		message := player.Value.Name
 		if player.Value.PickingNumber != 0 {
-			message = fmt.Sprintf("**%d)** %s", player.Value.PickingNumber, player.Key)
+			message = fmt.Sprintf("**%d)** %s", player.Value.PickingNumber, player.Value.Name)
 		}
 		sortedPlayerNames = append(sortedPlayerNames, message)
 	}
@@ -63,96 +142,85 @@ func (game *Game) BuildPlayerList() string {
 	return strings.Join(sortedPlayerNames, " :small_orange_diamond: ")
 }
 
-func (game *Game) RandPlayer() (string, *PlayerMetadata) {
-	keys := reflect.ValueOf(game.Players).MapKeys()
-	key := keys[rand.Intn(len(keys))].String()
-	return key, game.Players[key]
-}
-
-func (game *Game) BeginPicks(s *discordgo.Session, channelID string, modName string, mod *Mod) {
-	// TODO: Mention both players if mod has maxPlayers of 2
-	// TODO: Configurable countdown
-	var seconds int = 20
-	messageText := fmt.Sprintf("**%s** has filled.\nCaptains will be selected in `%d seconds`", modName, seconds)
-	message, error := s.ChannelMessageSend(channelID, messageText)
-
-	if error != nil {
-		return
-	}
-
-	countdownTicker := time.NewTicker(time.Second)
-	go func() {
-		for range countdownTicker.C {
-			seconds -= 1
-			log.Printf(fmt.Sprintf("Ticking %d for %p", seconds, game))
-
-			if !game.IsFull(mod) {
-				s.ChannelMessageEdit(channelID, message.ID, fmt.Sprintf("**%s** has filled.\n~~Captains will be selected in `%d seconds`~~", modName, seconds))
-				countdownTicker.Stop()
-			} else if seconds <= 0 && !game.IsPickingTeams(mod) {
-				messageText := fmt.Sprintf("**%s** has filled.\nCaptains have been selected", modName)
-				s.ChannelMessageEdit(channelID, message.ID, messageText)
-				countdownTicker.Stop()
-				game.AutoPickRemainingCaptains(s, channelID)
-			} else if game.IsFull(mod) && seconds > 0 && (seconds%5 == 0 || seconds < 5) {
-				messageText := fmt.Sprintf("**%s** has filled.\nCaptains will be selected in `%d seconds`", modName, seconds)
-				s.ChannelMessageEdit(channelID, message.ID, messageText)
-			} else if game.IsPickingTeams(mod) {
-				countdownTicker.Stop()
-			}
-		}
-	}()
+func (game *Game) countdownSeconds() int {
+	return int(time.Until(game.CountdownEnd).Round(time.Second).Seconds())
 }
 
-func (game *Game) AutoPickRemainingCaptains(s *discordgo.Session, channelID string) {
-	var message []string
-	for x := 0; x < 2; x++ {
-		randomPlayerName, randomPlayerMetadata := game.RandPlayer()
-		captainMessage := game.SetNextCaptainIfPossible(randomPlayerName, randomPlayerMetadata)
-		if captainMessage != "" {
-			message = append(message, captainMessage)
-		}
+func (mod *Mod) countdown() time.Duration {
+	if mod.Countdown == nil {
+		return CaptainCountdown
 	}
-	message = append(message, fmt.Sprintf("%s to pick", *game.RedCaptain))
+	return *mod.Countdown
+}
 
-	s.ChannelMessageSend(channelID, strings.Join(message, "\n"))
-	game.establishPickingNumbers()
-	s.ChannelMessageSend(channelID, game.BuildPlayerList())
+// Every how many seconds a live message counting down `duration` is updated:
+// every 5 seconds for the default captain countdown, less often for longer
+// ones. The last 5 seconds are always counted down one by one.
+func countdownUpdateSeconds(duration time.Duration) int {
+	interval := duration / 4
+	if interval >= 5*time.Second {
+		interval = interval.Truncate(5 * time.Second)
+	}
+	if interval < time.Second {
+		return 1
+	}
+	return int(interval / time.Second)
 }
 
 func (game *Game) SetCaptain(captain string, captainMetadata *PlayerMetadata, teamCaptain **string, team *map[string]*PlayerMetadata) {
 	delete(game.Players, captain)
 	*teamCaptain = &captain
 	(*team)[captain] = captainMetadata
+	if *game.RedCaptain != "" && *game.BlueCaptain != "" {
+		game.CaptainsTime = time.Now()
+	}
 }
 
-// Sets a captain to `userName` if there is room for them. Returns a message to send to a channel if so
-func (game *Game) SetNextCaptainIfPossible(userName string, userMetadata *PlayerMetadata) string {
+// Sets a captain to `userID` if there is room for them. Returns a message to send to a channel if so
+func (game *Game) SetNextCaptainIfPossible(userID string, userMetadata *PlayerMetadata) string {
 	var teamName string
-	if *game.RedCaptain == "" && *game.BlueCaptain != userName {
-		game.SetCaptain(userName, userMetadata, &game.RedCaptain, &game.Red)
+	if *game.RedCaptain == "" && *game.BlueCaptain != userID {
+		game.SetCaptain(userID, userMetadata, &game.RedCaptain, &game.Red)
 		teamName = "Red"
-	} else if *game.BlueCaptain == "" && *game.RedCaptain != userName {
-		game.SetCaptain(userName, userMetadata, &game.BlueCaptain, &game.Blue)
+	} else if *game.BlueCaptain == "" && *game.RedCaptain != userID {
+		game.SetCaptain(userID, userMetadata, &game.BlueCaptain, &game.Blue)
 		teamName = "Blue"
 	}
 
 	if teamName != "" {
-		return fmt.Sprintf("%s is captain for the **%s Team**", userName, teamName)
+		return fmt.Sprintf("%s is captain for the **%s Team**", mention(userID), teamName)
 	}
 
 	return ""
 }
 
-func (game *Game) PickColor() TeamColor {
-	pickedCount := len(game.Red) + len(game.Blue)
-	return pickedCount > 0 && ((pickedCount-1)/2)%2 == 1
+// Moves a player who is left to pick to a team.
+func (game *Game) Pick(userID string, color TeamColor) {
+	player := game.Players[userID]
+	player.PickedOrder = game.PickedPlayerCount()
+	game.team(color)[userID] = player
+	delete(game.Players, userID)
+	game.Picks = append(game.Picks, Pick{userID, color, game.captain(color)})
+}
+
+// Undoes the last pick. Returns false if nobody was picked.
+func (game *Game) Unpick() (Pick, bool) {
+	if len(game.Picks) == 0 {
+		return Pick{}, false
+	}
+	pick := game.Picks[len(game.Picks)-1]
+	game.Picks = game.Picks[:len(game.Picks)-1]
+	player := game.team(pick.Team)[pick.Player]
+	player.PickedOrder = 0
+	game.Players[pick.Player] = player
+	delete(game.team(pick.Team), pick.Player)
+	return pick, true
 }
 
-func (game *Game) NameByPickingNumber(i int) string {
-	for name, player := range game.Players {
+func (game *Game) IDByPickingNumber(i int) string {
+	for id, player := range game.Players {
 		if player.PickingNumber == i {
-			return name
+			return id
 		}
 	}
 	return ""
@@ -193,7 +261,7 @@ func PlayerNamesSortedByPick(team map[string]*PlayerMetadata) string {
 
 	var names []string
 	for _, player := range sortedPlayers {
-		names = append(names, player.Key)
+		names = append(names, player.Value.Name)
 	}
 
 	return strings.Join(names, " :small_orange_diamond: ")
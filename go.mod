@@ -1,13 +1,13 @@
-module main
+module github.com/UTPugs/discord-pugbot
 
 go 1.14
 
 require (
 	cloud.google.com/go/firestore v1.3.0
-	github.com/boltdb/bolt v1.3.1
-	github.com/bwmarrin/discordgo v0.20.3
+	github.com/bwmarrin/discordgo v0.27.1
 	github.com/google/logger v1.1.0
 	github.com/jasonlvhit/gocron v0.0.1
 	github.com/syndtr/goleveldb v1.0.0
+	go.etcd.io/bbolt v1.3.6
 	google.golang.org/api v0.29.0
 )
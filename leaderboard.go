@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Players per leaderboard page. Keeps pages well under MaxMessageLength even
+// with long names.
+const LeaderboardPageSize = 15
+
+const DefaultMinGames = 5
+
+func (mod *Mod) minGames() int {
+	if mod.MinGames == nil {
+		return DefaultMinGames
+	}
+	return *mod.MinGames
+}
+
+// PlayerRecord sums up the matches of a player in a mod.
+type PlayerRecord struct {
+	ID string
+	// Name the player had in their latest match.
+	Name      string
+	Games     int
+	Wins      int
+	Losses    int
+	Draws     int
+	Captained int
+	// Number of matches the player was picked in by a captain and the sum of
+	// their pick positions, 1 being the first pick.
+	Picked        int
+	PickPositions int
+	// Number of matches with each teammate by user ID.
+	Teammates  map[string]int
+	LastPlayed time.Time
+}
+
+// AveragePick is the average position the player was picked at, or 0 if they
+// never were.
+func (r *PlayerRecord) AveragePick() float64 {
+	if r.Picked == 0 {
+		return 0
+	}
+	return float64(r.PickPositions) / float64(r.Picked)
+}
+
+// WinRate is the share of matches with a result the player won.
+func (r *PlayerRecord) WinRate() float64 {
+	decided := r.Wins + r.Losses + r.Draws
+	if decided == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(decided)
+}
+
+// Returns the matches of a mod that weren't voided, oldest first.
+func (b *Bot) playedMatches(g GameIdentifier) []*Match {
+	var matches []*Match
+	for _, match := range b.matches {
+		if match.Channel == g.Channel && match.Mod == g.Mod && !match.Voided {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// Returns the matches of a mod that count for ratings, oldest first: those
+// that weren't voided and were played since the ratings were last reset.
+func (b *Bot) countedMatches(g GameIdentifier) []*Match {
+	mod := b.channels[g.Channel].Mods[g.Mod]
+	var matches []*Match
+	for _, match := range b.playedMatches(g) {
+		if !match.PickedTime.Before(mod.RatingsSince) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// Sums up `matches` by user ID.
+func playerRecords(matches []*Match) map[string]*PlayerRecord {
+	records := make(map[string]*PlayerRecord)
+	for _, match := range matches {
+		outcome := match.Outcome()
+		for _, side := range []struct {
+			players []MatchPlayer
+			won     Outcome
+		}{{match.Red, OutcomeRed}, {match.Blue, OutcomeBlue}} {
+			for _, player := range side.players {
+				record, ok := records[player.ID]
+				if !ok {
+					record = &PlayerRecord{ID: player.ID, Teammates: make(map[string]int)}
+					records[player.ID] = record
+				}
+				record.Name = player.Name
+				record.Games++
+				record.LastPlayed = match.PickedTime
+				if match.isCaptain(player.ID) {
+					record.Captained++
+				} else if player.PickedOrder > 0 && !player.Substitute {
+					record.Picked++
+					record.PickPositions += player.PickedOrder - 1
+				}
+				for _, teammate := range side.players {
+					if teammate.ID != player.ID {
+						record.Teammates[teammate.ID]++
+					}
+				}
+				switch outcome {
+				case OutcomeNone:
+				case OutcomeDraw:
+					record.Draws++
+				case side.won:
+					record.Wins++
+				default:
+					record.Losses++
+				}
+			}
+		}
+	}
+	return records
+}
+
+// Bot commands
+
+// Leaderboard ranks the players of a mod by rating.
+func (b *Bot) Leaderboard(ctx *Context, modName string, page int) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, modName)
+	if gameID == nil || mod == nil {
+		return
+	}
+	system := mod.ratingSystem()
+	var ranked []*PlayerRecord
+	for _, record := range playerRecords(b.countedMatches(*gameID)) {
+		if record.Games >= mod.minGames() {
+			ranked = append(ranked, record)
+		}
+	}
+	score := func(record *PlayerRecord) float64 {
+		return system.Score(b.rating(*gameID, record.ID))
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if score(ranked[i]) != score(ranked[j]) {
+			return score(ranked[i]) > score(ranked[j])
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+
+	if len(ranked) == 0 {
+		if mod.minGames() > 1 {
+			ctx.Reply(fmt.Sprintf("Nobody played %d **%s** matches yet", mod.minGames(), modName))
+		} else {
+			ctx.Reply(fmt.Sprintf("No **%s** match was played yet", modName))
+		}
+		return
+	}
+	pages := (len(ranked) + LeaderboardPageSize - 1) / LeaderboardPageSize
+	if page < 1 || page > pages {
+		ctx.Fail(fmt.Sprintf("Page should be between 1 and %d", pages))
+		return
+	}
+	lines := []string{
+		fmt.Sprintf("**%s** leaderboard (%s), page %d/%d", modName, mod.ratingSystemName(), page, pages),
+		"Rating :small_orange_diamond: games :small_orange_diamond: wins-losses-draws (win rate)",
+	}
+	start := (page - 1) * LeaderboardPageSize
+	for i := start; i < len(ranked) && i < start+LeaderboardPageSize; i++ {
+		record := ranked[i]
+		lines = append(lines, fmt.Sprintf("`%d.` **%s** %.0f :small_orange_diamond: %d games :small_orange_diamond: %d-%d-%d (%.0f%%)",
+			i+1, record.Name, score(record), record.Games, record.Wins, record.Losses, record.Draws, 100*record.WinRate()))
+	}
+	if mod.minGames() > 0 {
+		lines = append(lines, fmt.Sprintf("Players with fewer than %d games aren't listed", mod.minGames()))
+	}
+	ctx.ReplyLines(lines)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMinGamesOfStoredMod(t *testing.T) {
+	var mod Mod
+	if err := json.Unmarshal([]byte(`{"MaxPlayers":8}`), &mod); err != nil {
+		t.Fatal(err)
+	}
+	if mod.minGames() != DefaultMinGames {
+		t.Errorf("minGames() = %d for a mod stored without it, want %d", mod.minGames(), DefaultMinGames)
+	}
+
+	if err := findModSetting("mingames").Set(&mod, "0"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Mod
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.minGames() != 0 {
+		t.Errorf("minGames() = %d after setting 0, want 0", stored.minGames())
+	}
+}
@@ -8,25 +8,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"reflect"
 	"regexp"
-	"strconv"
-	"strings"
-	"sync"
 	"syscall"
 
-	"cloud.google.com/go/firestore"
 	"github.com/bwmarrin/discordgo"
 	"github.com/google/logger"
-	"github.com/jasonlvhit/gocron"
-	"google.golang.org/api/iterator"
 )
 
 // Variables used for command line parameters
 var (
-	Token string
-	Local string
-	bot   Bot
+	Token       string
+	Local       string
+	StorageKind string
+	StoragePath string
+	Readme      string
+	Recompute   bool
+	bot         *Bot
 )
 
 const logPath = "bot.log"
@@ -38,10 +35,20 @@ func init() {
 
 	flag.StringVar(&Token, "t", "", "Bot Token")
 	flag.StringVar(&Local, "l", "", "Local firebase host")
-	flag.Parse()
+	flag.StringVar(&StorageKind, "storage", StorageFirestore, "Storage backend: firestore, bolt, leveldb or memory")
+	flag.StringVar(&StoragePath, "db", "pugbot.db", "Database path for the bolt and leveldb backends")
+	flag.StringVar(&Readme, "readme", "", "Regenerate the command table of the given README and exit")
+	flag.BoolVar(&Recompute, "recompute-ratings", false, "Recompute all ratings from the match history and exit")
 }
 
 func main() {
+	flag.Parse()
+	if Readme != "" {
+		if err := updateReadme(Readme); err != nil {
+			log.Fatalf("Failed to update %s: %v", Readme, err)
+		}
+		return
+	}
 	lf, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
 	if Local != "" {
 		os.Setenv(FirestoreEmulatorHost, Local)
@@ -61,7 +68,7 @@ func main() {
 	}
 	dg, err := discordgo.New("Bot " + token)
 	if err != nil {
-		logger.Fatalf("error creating Discord session,", err)
+		logger.Fatalf("error creating Discord session: %v", err)
 		return
 	}
 	botname := os.Getenv("BOTNAME")
@@ -69,44 +76,41 @@ func main() {
 		botname = "discord-pugbot"
 	}
 
-	// Get a Firestore client.
+	// Open the configured storage backend.
 	ctx := context.Background()
-	client := createClient(ctx, botname)
-	defer client.Close()
-	channels := make(map[string]*Channel)
-	games := make(map[GameIdentifier]*Game)
-
-	iter := client.Collection("channels").Documents(ctx)
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			log.Fatalf("Failed to iterate: %v", err)
-		}
-		var c Channel
-		doc.DataTo(&c)
-		channels[doc.Ref.ID] = &c
-		for name := range c.Mods {
-			g := GameIdentifier{doc.Ref.ID, name}
-			games[g] = &Game{Players: make(map[string]*PlayerMetadata), Red: make(map[string]*PlayerMetadata), Blue: make(map[string]*PlayerMetadata), mutex: new(sync.Mutex), RedCaptain: new(string), BlueCaptain: new(string)}
-		}
+	storage, err := NewStorage(ctx, StorageKind, botname, StoragePath)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", StorageKind, err)
+	}
+	defer storage.Close()
+	bot, err = NewBot(storage, NewDiscordMessenger(dg))
+	if err != nil {
+		log.Fatalf("Failed to start bot: %v", err)
+	}
+	if Recompute {
+		bot.RecomputeAllRatings()
+		log.Println("Recomputed all ratings")
+		return
 	}
-	s := gocron.NewScheduler()
-	bot = Bot{channels, games, client, ctx, s}
 
-	// Register the messageCreate func as a callback for MessageCreate events.
+	// Register the messageCreate func as a callback for MessageCreate events
+	// and interactionCreate for slash commands.
 	dg.AddHandler(messageCreate)
+	dg.AddHandler(interactionCreate)
+	dg.AddHandler(messageReactionAdd)
+	dg.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentMessageContent | discordgo.IntentsGuildMembers | discordgo.IntentsGuildMessageReactions
 
 	// Open a websocket connection to Discord and begin listening.
 	err = dg.Open()
 	if err != nil {
-		logger.Fatalf("error opening connection,", err)
+		logger.Fatalf("error opening connection: %v", err)
 		return
 	}
-	s.Every(5).Second().Do(bot.cleanupPlayers, dg)
-	s.Start()
+	bot.MigrateUsernames(discordUsernameResolver(dg))
+	if err := registerSlashCommands(dg); err != nil {
+		log.Printf("Failed to register slash commands: %v", err)
+	}
+	bot.Start()
 
 	// Wait here until CTRL-C or other term signal is received.
 	log.Println("Bot is now running. Press CTRL-C to exit.")
@@ -126,16 +130,6 @@ func main() {
 	dg.Close()
 }
 
-// Converts input string to a reflection-compatible corresponding method string, e.g.
-// .foo bar -> Foo
-func parseCommand(s string) string {
-	com := strings.Split(strings.TrimLeft(s, "."), " ")
-	if len(com) > 0 {
-		return strings.Title(strings.ToLower(com[0]))
-	}
-	return ""
-}
-
 // Returns all command arguments, i.e. all words except from the first one.
 // If the arguments include brackets, consider them as one arguments, e.g.
 // .foo bar "hello world" -> ["bar", "hello world"]
@@ -155,36 +149,43 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 			log.Println("Recovered in messageCreate", r)
 		}
 	}()
-	args := parseArguments(m.Content)
-	// Pass the original session and message arguments.
-	inputs := make([]reflect.Value, len(args)+2)
-	inputs[0] = reflect.ValueOf(s)
-	inputs[1] = reflect.ValueOf(m)
-	// Pass any additional arguments based on the message itself.
-	for i := range args {
-		val := args[i]
-		if valInt, err := strconv.Atoi(val); err == nil {
-			inputs[i+2] = reflect.ValueOf(valInt)
-		} else {
-			inputs[i+2] = reflect.ValueOf(val)
-		}
-	}
-
 	// Ignore all messages created by the bot itself
 	// This isn't required in this specific example but it's a good practice.
 	if m.Author.ID == s.State.User.ID {
 		return
 	}
-	method := reflect.ValueOf(&bot).MethodByName(parseCommand(m.Content))
-	if method.IsValid() && len(inputs) >= method.Type().NumIn() {
-		// Trim all unnecessary arguments.
-		log.Printf("Calling bot method %v", inputs)
-		if !method.Type().IsVariadic() {
-			inputs = inputs[:method.Type().NumIn()]
+	bot.runCommand(newMessageContext(s, m, bot.messenger), m.Content)
+	bot.keepAlive(m.Author.ID)
+}
+
+func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Recovered in interactionCreate", r)
 		}
-		method.Call(inputs)
+	}()
+	bot.handleInteraction(s, i)
+	if i.Member != nil {
+		bot.keepAlive(i.Member.User.ID)
 	}
-	bot.keepAlive(m.Author.Username)
+}
+
+// Confirms ready checks players react to.
+func messageReactionAdd(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Recovered in messageReactionAdd", r)
+		}
+	}()
+	if r.UserID == s.State.User.ID || r.Emoji.Name != ReadyEmoji {
+		return
+	}
+	user := User{ID: r.UserID}
+	if r.Member != nil && r.Member.User != nil {
+		user.Username, user.Nick = r.Member.User.Username, r.Member.Nick
+	}
+	bot.readyReaction(r.ChannelID, r.MessageID, user)
+	bot.keepAlive(r.UserID)
 }
 
 func handler(w http.ResponseWriter, r *http.Request) {
@@ -194,13 +195,3 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	}
 	fmt.Fprintf(w, "Hello %s!\n", name)
 }
-
-func createClient(ctx context.Context, token string) *firestore.Client {
-	client, err := firestore.NewClient(ctx, token)
-	if err != nil {
-		log.Fatalf("Failed to create client: %v", err)
-	}
-	// Close client when done with
-	// defer client.Close()
-	return client
-}
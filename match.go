@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Most matches .last shows at once.
+const MaxLastMatches = 10
+
+// Match is the record of a game whose teams were picked. Matches are kept
+// forever and are what results, ratings and stats are based on.
+type Match struct {
+	// Numbered from 1 in the order teams were completed, across all channels.
+	ID          int
+	Channel     string
+	Mod         string
+	RedCaptain  string
+	BlueCaptain string
+	// Players ordered by when they were picked, captains first.
+	Red  []MatchPlayer
+	Blue []MatchPlayer
+	// When the game filled up, when both captains were known and when the
+	// last player was picked.
+	FilledTime   time.Time
+	CaptainsTime time.Time
+	PickedTime   time.Time
+	// Nil until someone reports how the match went.
+	Result *MatchResult
+	// Voided matches don't count for ratings and stats.
+	Voided bool
+}
+
+type MatchPlayer struct {
+	ID string
+	// Display name of the player when they joined
+	Name     string
+	JoinTime time.Time
+	// 0 for captains, players of balanced teams and substitutes, 2 for the
+	// first player picked and so on.
+	PickedOrder int
+	// Whether the player came in for someone else after the teams were picked.
+	Substitute bool
+}
+
+// Records the teams of a game that just finished picking.
+func (game *Game) match(id int, g GameIdentifier) *Match {
+	return &Match{
+		ID:           id,
+		Channel:      g.Channel,
+		Mod:          g.Mod,
+		RedCaptain:   *game.RedCaptain,
+		BlueCaptain:  *game.BlueCaptain,
+		Red:          matchPlayers(game.Red),
+		Blue:         matchPlayers(game.Blue),
+		FilledTime:   game.FilledTime,
+		CaptainsTime: game.CaptainsTime,
+		PickedTime:   time.Now(),
+	}
+}
+
+func matchPlayers(team map[string]*PlayerMetadata) []MatchPlayer {
+	var players []MatchPlayer
+	for id, player := range team {
+		players = append(players, MatchPlayer{ID: id, Name: player.Name, JoinTime: player.JoinTime, PickedOrder: player.PickedOrder})
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].PickedOrder != players[j].PickedOrder {
+			return players[i].PickedOrder < players[j].PickedOrder
+		}
+		return players[i].JoinTime.Before(players[j].JoinTime)
+	})
+	return players
+}
+
+// Stores a match for the teams of `g` and returns it.
+func (b *Bot) recordMatch(g GameIdentifier) *Match {
+	id := 1
+	if len(b.matches) > 0 {
+		id = b.matches[len(b.matches)-1].ID + 1
+	}
+	match := b.games[g].match(id, g)
+	b.matches = append(b.matches, match)
+	b.saveMatch(match)
+	return match
+}
+
+func (b *Bot) saveMatch(match *Match) {
+	if err := b.storage.SaveMatch(match); err != nil {
+		log.Printf("Failed to save match %d: %s", match.ID, err)
+	}
+}
+
+// Returns up to `count` matches of the channel, newest first, skipping the
+// `skip` newest ones. An empty `modName` matches all mods.
+func (b *Bot) lastMatches(channelID string, modName string, skip int, count int) []*Match {
+	var matches []*Match
+	for i := len(b.matches) - 1; i >= 0 && len(matches) < count; i-- {
+		match := b.matches[i]
+		if match.Channel != channelID || (modName != "" && match.Mod != modName) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+func (match *Match) captainName(captainID string) string {
+	for _, team := range [][]MatchPlayer{match.Red, match.Blue} {
+		for _, player := range team {
+			if player.ID == captainID {
+				return player.Name
+			}
+		}
+	}
+	return "nobody"
+}
+
+// Summary shows the captains, teams and age of the match.
+func (match *Match) Summary() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "**%s** match **#%d**, %s\n", match.Mod, match.ID, formatAge(time.Since(match.PickedTime)))
+	fmt.Fprintf(&builder, "Captains: %s (Red) :small_orange_diamond: %s (Blue)\n", match.captainName(match.RedCaptain), match.captainName(match.BlueCaptain))
+	fmt.Fprintf(&builder, "**Red**: %s\n", matchPlayerNames(match.Red))
+	fmt.Fprintf(&builder, "**Blue**: %s", matchPlayerNames(match.Blue))
+	if match.Voided {
+		builder.WriteString("\nVoided")
+	} else if match.Result != nil {
+		fmt.Fprintf(&builder, "\n%s", match.Result)
+	}
+	return builder.String()
+}
+
+func matchPlayerNames(team []MatchPlayer) string {
+	var names []string
+	for _, player := range team {
+		names = append(names, player.Name)
+	}
+	return strings.Join(names, " :small_orange_diamond: ")
+}
+
+// Describes how long ago something happened, e.g. "23 minutes ago".
+func formatAge(age time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return plural(int(age/time.Minute), "minute")
+	case age < 24*time.Hour:
+		return plural(int(age/time.Hour), "hour")
+	}
+	return plural(int(age/(24*time.Hour)), "day")
+}
+
+// Bot commands
+
+// Last shows the `count` latest matches on the channel, optionally only those
+// of one mod, after skipping the `skip` latest ones.
+func (b *Bot) Last(ctx *Context, modName string, skip int, count int) {
+	if count < 1 || count > MaxLastMatches {
+		ctx.Fail(fmt.Sprintf("Count should be between 1 and %d", MaxLastMatches))
+		return
+	}
+	matches := b.lastMatches(ctx.ChannelID, modName, skip, count)
+	if len(matches) == 0 {
+		if modName != "" {
+			ctx.Reply(fmt.Sprintf("No **%s** match was played yet", modName))
+		} else {
+			ctx.Reply("No match was played on this channel yet")
+		}
+		return
+	}
+	var summaries []string
+	for i, match := range matches {
+		if i > 0 {
+			summaries = append(summaries, "")
+		}
+		summaries = append(summaries, match.Summary())
+	}
+	ctx.ReplyLines(summaries)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatchRecordedWhenTeamsAreSelected(t *testing.T) {
+	tb := newTestBot(t, 6, "countdown 0", "pickorder alternate")
+	tb.join(1, 6)
+	game := tb.game()
+	red, blue := *game.RedCaptain, *game.BlueCaptain
+	for tb.game().IsPickingTeams(tb.mod()) {
+		tb.pickTurn()
+	}
+
+	stored, err := tb.storage.Matches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].ID != 1 {
+		t.Fatalf("stored %d matches, want match #1", len(stored))
+	}
+	match := tb.matches[0]
+	if match.Mod != "ctf" || match.Channel != testChannel {
+		t.Errorf("match of %s in %s, want ctf in %s", match.Mod, match.Channel, testChannel)
+	}
+	if match.RedCaptain != red || match.BlueCaptain != blue {
+		t.Errorf("captains %s and %s, want %s and %s", match.RedCaptain, match.BlueCaptain, red, blue)
+	}
+	if match.Red[0].ID != red || match.Blue[0].ID != blue {
+		t.Errorf("captains should be listed first")
+	}
+	// Alternate picks: red 2 and 4, blue 3 and 5.
+	for i, want := range []int{0, 2, 4} {
+		if got := match.Red[i].PickedOrder; got != want {
+			t.Errorf("red player %d picked %d, want %d", i, got, want)
+		}
+		if got := match.Blue[i].PickedOrder; want > 0 && got != want+1 {
+			t.Errorf("blue player %d picked %d, want %d", i, got, want+1)
+		}
+	}
+	if match.FilledTime.IsZero() || match.CaptainsTime.IsZero() || match.PickedTime.Before(match.CaptainsTime) {
+		t.Errorf("times filled %s, captains %s, picked %s", match.FilledTime, match.CaptainsTime, match.PickedTime)
+	}
+	if match.Result != nil {
+		t.Errorf("new match has result %s", match.Result)
+	}
+}
+
+// Fills the mod with players `from` to `to` and picks the teams.
+func (tb *testBot) playMatch(from int, to int) {
+	tb.join(from, to)
+	for tb.game().IsPickingTeams(tb.mod()) {
+		tb.pickTurn()
+	}
+}
+
+func TestLast(t *testing.T) {
+	tb := newTestBot(t, 2, "countdown 0")
+	tb.run(testUser(1), ".last")
+	tb.expectSent("No match was played on this channel yet")
+	tb.playMatch(1, 2)
+	tb.playMatch(3, 4)
+
+	tests := []struct {
+		command string
+		want    []string
+		notWant string
+	}{
+		{".last", []string{"match **#2**"}, "#1"},
+		{".last ctf", []string{"match **#2**"}, "#1"},
+		{".lastt", []string{"match **#1**"}, "#2"},
+		{".last 2", []string{"match **#2**", "match **#1**"}, ""},
+		{".last ctf 2", []string{"match **#2**", "match **#1**"}, ""},
+		{".teams ctf", []string{"match **#2**"}, "#1"},
+		{fmt.Sprintf(".last %d", MaxLastMatches+1), []string{fmt.Sprintf("Count should be between 1 and %d", MaxLastMatches)}, ""},
+	}
+	for _, test := range tests {
+		tb.messenger.Reset()
+		tb.run(testUser(1), test.command)
+		sent := strings.Join(tb.messenger.Sent(testChannel), "\n")
+		for _, want := range test.want {
+			if !strings.Contains(sent, want) {
+				t.Errorf("%s: sent %q, want %q", test.command, sent, want)
+			}
+		}
+		if test.notWant != "" && strings.Contains(sent, test.notWant) {
+			t.Errorf("%s: sent %q, didn't want %q", test.command, sent, test.notWant)
+		}
+	}
+}
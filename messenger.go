@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Messenger is how the bot talks to players. The Discord implementation is
+// used when running, the tests' RecordingMessenger lets the game logic run
+// without Discord.
+type Messenger interface {
+	// Send posts a message to a channel and returns its ID.
+	Send(channelID string, content string) (string, error)
+	// SendButton posts a message with a button labelled `label`. Pressing it
+	// sends an interaction with `customID`.
+	SendButton(channelID string, content string, label string, customID string) (string, error)
+	Edit(channelID string, messageID string, content string) error
+	React(channelID string, messageID string, emoji string) error
+	// DM sends a direct message to a user.
+	DM(userID string, content string) error
+}
+
+// Only users may be pinged by the bot, never roles or @everyone.
+var allowedMentions = &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers}}
+
+type discordMessenger struct {
+	session *discordgo.Session
+}
+
+func NewDiscordMessenger(s *discordgo.Session) Messenger {
+	return &discordMessenger{s}
+}
+
+func (d *discordMessenger) Send(channelID string, content string) (string, error) {
+	message, err := d.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content, AllowedMentions: allowedMentions})
+	if err != nil {
+		return "", err
+	}
+	return message.ID, nil
+}
+
+func (d *discordMessenger) SendButton(channelID string, content string, label string, customID string) (string, error) {
+	message, err := d.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: allowedMentions,
+		Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: label, Style: discordgo.SuccessButton, CustomID: customID},
+		}}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return message.ID, nil
+}
+
+func (d *discordMessenger) Edit(channelID string, messageID string, content string) error {
+	_, err := d.session.ChannelMessageEdit(channelID, messageID, content)
+	return err
+}
+
+func (d *discordMessenger) React(channelID string, messageID string, emoji string) error {
+	return d.session.MessageReactionAdd(channelID, messageID, emoji)
+}
+
+func (d *discordMessenger) DM(userID string, content string) error {
+	channel, err := d.session.UserChannelCreate(userID)
+	if err != nil {
+		return err
+	}
+	_, err = d.session.ChannelMessageSend(channel.ID, content)
+	return err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+type MessageKind int
+
+const (
+	MessageSent MessageKind = iota
+	MessageEdited
+	MessageReacted
+	MessageDirect
+)
+
+// RecordedMessage is a single call made to a RecordingMessenger. For direct
+// messages ChannelID holds the user ID, for reactions Content holds the emoji.
+type RecordedMessage struct {
+	Kind      MessageKind
+	ChannelID string
+	MessageID string
+	Content   string
+}
+
+// RecordingMessenger keeps everything the bot would have sent to Discord.
+type RecordingMessenger struct {
+	mutex    sync.Mutex
+	messages []RecordedMessage
+	lastID   int
+}
+
+func (r *RecordingMessenger) record(message RecordedMessage) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.messages = append(r.messages, message)
+}
+
+func (r *RecordingMessenger) Send(channelID string, content string) (string, error) {
+	r.mutex.Lock()
+	r.lastID++
+	messageID := strconv.Itoa(r.lastID)
+	r.mutex.Unlock()
+	r.record(RecordedMessage{MessageSent, channelID, messageID, content})
+	return messageID, nil
+}
+
+// SendButton records the message like Send, the button isn't recorded.
+func (r *RecordingMessenger) SendButton(channelID string, content string, label string, customID string) (string, error) {
+	return r.Send(channelID, content)
+}
+
+func (r *RecordingMessenger) Edit(channelID string, messageID string, content string) error {
+	r.record(RecordedMessage{MessageEdited, channelID, messageID, content})
+	return nil
+}
+
+func (r *RecordingMessenger) React(channelID string, messageID string, emoji string) error {
+	r.record(RecordedMessage{MessageReacted, channelID, messageID, emoji})
+	return nil
+}
+
+func (r *RecordingMessenger) DM(userID string, content string) error {
+	r.record(RecordedMessage{MessageDirect, userID, "", content})
+	return nil
+}
+
+// Messages returns a copy of everything recorded so far.
+func (r *RecordingMessenger) Messages() []RecordedMessage {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]RecordedMessage(nil), r.messages...)
+}
+
+// Sent returns the content of all messages sent to `channelID`.
+func (r *RecordingMessenger) Sent(channelID string) []string {
+	var sent []string
+	for _, message := range r.Messages() {
+		if message.Kind == MessageSent && message.ChannelID == channelID {
+			sent = append(sent, message.Content)
+		}
+	}
+	return sent
+}
+
+// Last returns the last message sent to `channelID`, or an empty string.
+func (r *RecordingMessenger) Last(channelID string) string {
+	sent := r.Sent(channelID)
+	if len(sent) == 0 {
+		return ""
+	}
+	return sent[len(sent)-1]
+}
+
+// Reset forgets everything recorded so far.
+func (r *RecordingMessenger) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.messages = nil
+}
+
+func (k MessageKind) String() string {
+	switch k {
+	case MessageEdited:
+		return "edit"
+	case MessageReacted:
+		return "react"
+	case MessageDirect:
+		return "dm"
+	}
+	return "send"
+}
+
+func (m RecordedMessage) String() string {
+	return fmt.Sprintf("%s %s/%s: %s", m.Kind, m.ChannelID, m.MessageID, m.Content)
+}
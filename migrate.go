@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var snowflakeRegexp = regexp.MustCompile(`^\d{15,}$`)
+
+// Looks up a member of the guild `channelID` belongs to by username.
+type usernameResolver func(channelID string, username string) (User, bool)
+
+// Players used to be keyed by username. MigrateUsernames rewrites stored games
+// that still are to user IDs. Games with players that can't be found anymore
+// are started over.
+func (b *Bot) MigrateUsernames(resolve usernameResolver) {
+	b.lock()
+	defer b.unlock()
+	for g, game := range b.games {
+		if !game.hasUsernameKeys() {
+			continue
+		}
+		if game.migrateUsernames(func(username string) (User, bool) { return resolve(g.Channel, username) }) {
+			log.Printf("Migrated players of %v to user IDs", g)
+		} else {
+			log.Printf("Couldn't find all players of %v, starting it over", g)
+			b.games[g] = NewGame()
+		}
+		b.saveGame(g)
+	}
+}
+
+func (game *Game) hasUsernameKeys() bool {
+	for _, team := range []map[string]*PlayerMetadata{game.Players, game.Red, game.Blue} {
+		for key := range team {
+			if !snowflakeRegexp.MatchString(key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rekeys all players and captains by user ID. Returns false if a player
+// couldn't be resolved.
+func (game *Game) migrateUsernames(resolve func(username string) (User, bool)) bool {
+	ids := make(map[string]string)
+	for _, team := range []*map[string]*PlayerMetadata{&game.Players, &game.Red, &game.Blue} {
+		migrated := make(map[string]*PlayerMetadata)
+		for key, player := range *team {
+			if snowflakeRegexp.MatchString(key) {
+				migrated[key] = player
+				continue
+			}
+			user, ok := resolve(key)
+			if !ok {
+				return false
+			}
+			if player.Name == "" {
+				player.Name = user.DisplayName()
+			}
+			ids[key] = user.ID
+			migrated[user.ID] = player
+		}
+		*team = migrated
+	}
+	for _, captain := range []*string{game.RedCaptain, game.BlueCaptain} {
+		if id, ok := ids[*captain]; ok {
+			*captain = id
+		}
+	}
+	return true
+}
+
+// Resolves usernames by searching the members of the channel's guild.
+func discordUsernameResolver(s *discordgo.Session) usernameResolver {
+	return func(channelID string, username string) (User, bool) {
+		channel, err := s.Channel(channelID)
+		if err != nil {
+			log.Printf("Failed to look up channel %s: %s", channelID, err)
+			return User{}, false
+		}
+		members, err := s.GuildMembersSearch(channel.GuildID, username, 10)
+		if err != nil {
+			log.Printf("Failed to search for %s: %s", username, err)
+			return User{}, false
+		}
+		for _, member := range members {
+			if member.User.Username == username {
+				return User{member.User.ID, member.User.Username, member.Nick}, true
+			}
+		}
+		return User{}, false
+	}
+}
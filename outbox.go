@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// How many sent messages the outbox remembers the Discord ID of. Older ones
+// can't be edited or reacted to through their queued ID anymore.
+const MaxTrackedMessages = 1000
+
+// Prefix of the IDs the outbox hands out for messages that may not be sent yet.
+const queuedIDPrefix = "queued-"
+
+// outbox is the Messenger the bot uses while it holds its mutex. Nothing is
+// sent right away: messages are queued in the order they were made and sent
+// by flush once the mutex is released, so a slow or rate limited Discord
+// doesn't keep other commands and timers waiting for the mutex. Whoever
+// flushes still waits until the messages queued before theirs are sent too.
+//
+// Send returns an ID of its own since the message isn't sent yet. Edits and
+// reactions queued with it go to the real message, which is always sent
+// first.
+type outbox struct {
+	messenger Messenger
+	// Guards the fields below.
+	mutex  sync.Mutex
+	queue  []func()
+	lastID int
+	// Discord IDs of sent messages by queued ID, oldest first in `sent`.
+	ids  map[string]string
+	sent []string
+	// Held while sending so that messages go out in the order they were
+	// queued, whichever goroutine flushes.
+	sending sync.Mutex
+}
+
+func newOutbox(messenger Messenger) *outbox {
+	return &outbox{messenger: messenger, ids: make(map[string]string)}
+}
+
+func (o *outbox) enqueue(send func()) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.queue = append(o.queue, send)
+}
+
+// Queues a message that is sent with `send`. Returns the queued ID.
+func (o *outbox) enqueueMessage(channelID string, send func() (string, error)) string {
+	o.mutex.Lock()
+	o.lastID++
+	id := fmt.Sprintf("%s%d", queuedIDPrefix, o.lastID)
+	o.mutex.Unlock()
+	o.enqueue(func() {
+		messageID, err := send()
+		if err != nil {
+			log.Printf("Failed to send a message to %s: %s", channelID, err)
+			return
+		}
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		o.ids[id] = messageID
+		o.sent = append(o.sent, id)
+		if len(o.sent) > MaxTrackedMessages {
+			delete(o.ids, o.sent[0])
+			o.sent = o.sent[1:]
+		}
+	})
+	return id
+}
+
+// Returns the Discord ID of a message that was sent through the outbox. Other
+// IDs are returned as they are.
+func (o *outbox) resolve(id string) string {
+	if !strings.HasPrefix(id, queuedIDPrefix) {
+		return id
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if messageID, ok := o.ids[id]; ok {
+		return messageID
+	}
+	return id
+}
+
+func (o *outbox) Send(channelID string, content string) (string, error) {
+	return o.enqueueMessage(channelID, func() (string, error) {
+		return o.messenger.Send(channelID, content)
+	}), nil
+}
+
+func (o *outbox) SendButton(channelID string, content string, label string, customID string) (string, error) {
+	return o.enqueueMessage(channelID, func() (string, error) {
+		return o.messenger.SendButton(channelID, content, label, customID)
+	}), nil
+}
+
+func (o *outbox) Edit(channelID string, messageID string, content string) error {
+	o.enqueue(func() {
+		if err := o.messenger.Edit(channelID, o.resolve(messageID), content); err != nil {
+			log.Printf("Failed to edit message %s: %s", messageID, err)
+		}
+	})
+	return nil
+}
+
+func (o *outbox) React(channelID string, messageID string, emoji string) error {
+	o.enqueue(func() {
+		if err := o.messenger.React(channelID, o.resolve(messageID), emoji); err != nil {
+			log.Printf("Failed to react to message %s: %s", messageID, err)
+		}
+	})
+	return nil
+}
+
+func (o *outbox) DM(userID string, content string) error {
+	o.enqueue(func() {
+		if err := o.messenger.DM(userID, content); err != nil {
+			log.Printf("Failed to send a direct message to %s: %s", userID, err)
+		}
+	})
+	return nil
+}
+
+// Sends everything queued so far, including what other goroutines queue
+// while this one is sending.
+func (o *outbox) flush() {
+	o.sending.Lock()
+	defer o.sending.Unlock()
+	for {
+		o.mutex.Lock()
+		queue := o.queue
+		o.queue = nil
+		o.mutex.Unlock()
+		if len(queue) == 0 {
+			return
+		}
+		for _, send := range queue {
+			send()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutbox(t *testing.T) {
+	recorder := &RecordingMessenger{}
+	o := newOutbox(recorder)
+	id, _ := o.Send("channel", "counting 2")
+	o.Edit("channel", id, "counting 1")
+	o.React("channel", id, ReadyEmoji)
+	if len(recorder.Messages()) != 0 {
+		t.Fatalf("sent before flush: %v", recorder.Messages())
+	}
+
+	o.flush()
+	var got []string
+	for _, message := range recorder.Messages() {
+		got = append(got, message.String())
+	}
+	want := []string{"send channel/1: counting 2", "edit channel/1: counting 1", "react channel/1: " + ReadyEmoji}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sent:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if o.resolve(id) != "1" {
+		t.Errorf("resolve(%q) = %q, want 1", id, o.resolve(id))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PermissionLevel is what a user is allowed to do on a channel. Every level
+// includes the ones below it.
+type PermissionLevel int
+
+const (
+	PermissionEveryone PermissionLevel = iota
+	// Picks for either team and reports results.
+	PermissionReferee
+	// Manages games: adds players, resets games and forces captains.
+	PermissionModerator
+	// Configures the bot on the channel.
+	PermissionAdmin
+)
+
+// Names of the levels that can be granted, lowest first.
+var permissionLevelNames = []string{"referee", "moderator", "admin"}
+
+func (l PermissionLevel) String() string {
+	switch l {
+	case PermissionReferee:
+		return "referee"
+	case PermissionModerator:
+		return "moderator"
+	case PermissionAdmin:
+		return "admin"
+	}
+	return "everyone"
+}
+
+func parsePermissionLevel(name string) PermissionLevel {
+	for i, levelName := range permissionLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return PermissionReferee + PermissionLevel(i)
+		}
+	}
+	return PermissionEveryone
+}
+
+// Permissions grants levels on a channel to roles and users. Guild
+// administrators are always admins, so that they can set up the bot.
+type Permissions struct {
+	// Levels by role ID.
+	Roles map[string]PermissionLevel
+	// Levels by user ID.
+	Users map[string]PermissionLevel
+}
+
+// Level returns the highest level granted to `userID` or one of `roleIDs`.
+func (p *Permissions) Level(userID string, roleIDs []string) PermissionLevel {
+	level := p.Users[userID]
+	for _, roleID := range roleIDs {
+		if p.Roles[roleID] > level {
+			level = p.Roles[roleID]
+		}
+	}
+	return level
+}
+
+func (p *Permissions) grants(target Mentionable) map[string]PermissionLevel {
+	if target.Role {
+		if p.Roles == nil {
+			p.Roles = make(map[string]PermissionLevel)
+		}
+		return p.Roles
+	}
+	if p.Users == nil {
+		p.Users = make(map[string]PermissionLevel)
+	}
+	return p.Users
+}
+
+// Returns the level of the author of the command on its channel.
+func (b *Bot) permissionLevel(ctx *Context) PermissionLevel {
+	if ctx.GuildAdmin {
+		return PermissionAdmin
+	}
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		return c.Permissions.Level(ctx.User.ID, ctx.Roles)
+	}
+	return PermissionEveryone
+}
+
+func (b *Bot) hasPermission(ctx *Context, level PermissionLevel) bool {
+	return b.permissionLevel(ctx) >= level
+}
+
+// Bot commands
+
+func (b *Bot) Permadd(ctx *Context, target Mentionable, level PermissionLevel) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		c.Permissions.grants(target)[target.ID] = level
+		if !b.saveChannel(ctx.ChannelID) {
+			return
+		}
+		ctx.Reply(fmt.Sprintf("%s is now %s", target, level))
+	}
+}
+
+func (b *Bot) Permremove(ctx *Context, target Mentionable) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		grants := c.Permissions.grants(target)
+		if _, ok := grants[target.ID]; !ok {
+			ctx.Fail(fmt.Sprintf("%s has no permissions on this channel", target))
+			return
+		}
+		delete(grants, target.ID)
+		if !b.saveChannel(ctx.ChannelID) {
+			return
+		}
+		ctx.Reply(fmt.Sprintf("%s no longer has permissions on this channel", target))
+	}
+}
+
+func (b *Bot) Permlist(ctx *Context) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		var lines []string
+		for id, level := range c.Permissions.Roles {
+			lines = append(lines, fmt.Sprintf("%s :small_orange_diamond: %s", Mentionable{ID: id, Role: true}, level))
+		}
+		for id, level := range c.Permissions.Users {
+			lines = append(lines, fmt.Sprintf("%s :small_orange_diamond: %s", Mentionable{ID: id}, level))
+		}
+		if len(lines) == 0 {
+			ctx.Reply("Only server admins have permissions on this channel")
+			return
+		}
+		sort.Strings(lines)
+		ctx.ReplyLines(append([]string{"**Permissions**"}, lines...))
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A pick order is a sequence of turns such as "ABBA": A for the red captain,
+// B for the blue one. It repeats until everyone is picked.
+const DefaultPickOrder = "ABBA"
+
+var (
+	pickOrderPresets = map[string]string{
+		"alternate": "AB",
+		"snake":     "ABBA",
+	}
+	pickOrderRegexp      = regexp.MustCompile(`^[AB]+$`)
+	pickOrderCountRegexp = regexp.MustCompile(`^[1-9](-[1-9])*$`)
+)
+
+// Parses a preset, a sequence such as ABBAABBA or the number of picks per turn
+// such as 1-2-2-1, red first. Returns the sequence.
+func parsePickOrder(value string) (string, error) {
+	if order, ok := pickOrderPresets[strings.ToLower(value)]; ok {
+		return order, nil
+	}
+	if order := strings.ToUpper(value); pickOrderRegexp.MatchString(order) {
+		return order, nil
+	}
+	if pickOrderCountRegexp.MatchString(value) {
+		var order strings.Builder
+		for i, count := range strings.Split(value, "-") {
+			n, _ := strconv.Atoi(count)
+			order.WriteString(strings.Repeat(string("AB"[i%2]), n))
+		}
+		return order.String(), nil
+	}
+	return "", fmt.Errorf("pickorder should be alternate, snake, a sequence like ABBAABBA or turns like 1-2-2-1, got `%s`", value)
+}
+
+func (mod *Mod) pickOrder() string {
+	if mod.PickOrder == "" {
+		return DefaultPickOrder
+	}
+	return mod.PickOrder
+}
+
+// Describes the pick order of a mod, by its preset name if it has one.
+func (mod *Mod) pickOrderName() string {
+	for _, name := range []string{"alternate", "snake"} {
+		if pickOrderPresets[name] == mod.pickOrder() {
+			return name
+		}
+	}
+	return mod.pickOrder()
+}
+
+// Whose turn the `pick`th pick is, counting from 0, when the teams have `red`
+// and `blue` players. A full team is skipped.
+func (mod *Mod) pickColor(pick int, red int, blue int) TeamColor {
+	order := mod.pickOrder()
+	teamSize := (mod.MaxPlayers + 1) / 2
+	color := TeamColor(order[pick%len(order)] == 'B')
+	if color == Red && red >= teamSize {
+		return Blue
+	}
+	if color == Blue && blue >= teamSize {
+		return Red
+	}
+	return color
+}
+
+// Returns the team of every remaining pick of a game, in order.
+func (game *Game) upcomingPicks(mod *Mod) []TeamColor {
+	red, blue := len(game.Red), len(game.Blue)
+	var picks []TeamColor
+	for i := 0; i < len(game.Players); i++ {
+		color := mod.pickColor(red+blue-2, red, blue)
+		if color == Red {
+			red++
+		} else {
+			blue++
+		}
+		picks = append(picks, color)
+	}
+	return picks
+}
+
+func (game *Game) PickColor(mod *Mod) TeamColor {
+	return mod.pickColor(game.PickedPlayerCount()-2, len(game.Red), len(game.Blue))
+}
+
+// Returns whose turn it is and how many players they pick in it. The last
+// player left is never part of a turn, they join their team automatically.
+func (game *Game) PickTurn(mod *Mod) (TeamColor, int) {
+	picks := game.upcomingPicks(mod)
+	count := 1
+	for count < len(picks)-1 && picks[count] == picks[0] {
+		count++
+	}
+	return picks[0], count
+}
+
+// Describes the remaining turns of a game, e.g. "Red 1 → Blue 2 → Red 1".
+func (game *Game) PickOrderStatus(mod *Mod) string {
+	var turns []string
+	picks := game.upcomingPicks(mod)
+	// The last player isn't picked by anyone.
+	picks = picks[:len(picks)-1]
+	for i := 0; i < len(picks); {
+		count := 1
+		for i+count < len(picks) && picks[i+count] == picks[i] {
+			count++
+		}
+		turns = append(turns, fmt.Sprintf("%s %d", picks[i], count))
+		i += count
+	}
+	if len(turns) > 0 {
+		turns[0] = "**" + turns[0] + "**"
+	}
+	return "Pick order: " + strings.Join(turns, " → ")
+}
+
+func (color TeamColor) String() string {
+	if color == Blue {
+		return "Blue"
+	}
+	return "Red"
+}
+
+// Returns the user ID of the captain of a team.
+func (game *Game) captain(color TeamColor) string {
+	if color == Blue {
+		return *game.BlueCaptain
+	}
+	return *game.RedCaptain
+}
+
+func (game *Game) team(color TeamColor) map[string]*PlayerMetadata {
+	if color == Blue {
+		return game.Blue
+	}
+	return game.Red
+}
+
+// Tells the captain whose turn it is to pick and shows the pick order.
+func (game *Game) toPick(mod *Mod) string {
+	color, picks := game.PickTurn(mod)
+	message := fmt.Sprintf("%s to pick", mention(game.captain(color)))
+	if picks > 1 {
+		message = fmt.Sprintf("%s to pick %d players", mention(game.captain(color)), picks)
+	}
+	return message + "\n" + game.PickOrderStatus(mod)
+}
+
+// Puts the last player left to pick on their team.
+func (game *Game) pickLast(mod *Mod) {
+	for id := range game.Players {
+		game.Pick(id, game.PickColor(mod))
+	}
+}
+
+// Numbers the players left to pick and announces the first turn, after
+// `message` if there is one.
+func (b *Bot) startPicking(g GameIdentifier, message []string) {
+	game := b.games[g]
+	game.CountdownEnd = time.Time{}
+	game.establishPickingNumbers()
+	b.announceTurn(g, message)
+}
+
+// Tells the captain whose turn it is to pick, after `message` if there is
+// one, and starts the pick timer. If there aren't enough players left for a
+// turn, the teams are complete instead.
+func (b *Bot) announceTurn(g GameIdentifier, message []string) {
+	game := b.games[g]
+	mod := b.channels[g.Channel].Mods[g.Mod]
+	if len(game.Players) <= 1 {
+		game.pickLast(mod)
+		message = append(message, b.selectTeams(g))
+		b.messenger.Send(g.Channel, strings.Join(message, "\n"))
+		b.refill(g)
+		return
+	}
+	message = append(message, game.toPick(mod))
+	b.messenger.Send(g.Channel, strings.Join(message, "\n"))
+	b.messenger.Send(g.Channel, game.BuildPlayerList())
+	b.startPickTimer(g)
+}
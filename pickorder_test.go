@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestParsePickOrder(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{"alternate", "AB", false},
+		{"Snake", "ABBA", false},
+		{"abbaab", "ABBAAB", false},
+		{"1-2-2-1", "ABBAAB", false},
+		{"2", "AA", false},
+		{"", "", true},
+		{"ABC", "", true},
+		{"0-1", "", true},
+		{"1-", "", true},
+		{"1--2", "", true},
+		{"10-1", "", true},
+		{"-1-2", "", true},
+	}
+	for _, test := range tests {
+		got, err := parsePickOrder(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("parsePickOrder(%q) error = %v, want an error: %v", test.value, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parsePickOrder(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestPickColorSkipsFullTeam(t *testing.T) {
+	mod := &Mod{MaxPlayers: 6, PickOrder: "AAAB"}
+	tests := []struct {
+		pick, red, blue int
+		want            TeamColor
+	}{
+		{0, 1, 1, Red},
+		{1, 2, 1, Red},
+		{2, 3, 1, Blue},
+		{3, 3, 2, Blue},
+	}
+	for _, test := range tests {
+		if got := mod.pickColor(test.pick, test.red, test.blue); got != test.want {
+			t.Errorf("pick %d with %d red and %d blue: %s, want %s", test.pick, test.red, test.blue, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// What happens when a captain doesn't pick in time.
+const (
+	// The best rated players left are picked for them.
+	IdleCaptainHighest = "highest"
+	// The players who joined first are picked for them.
+	IdleCaptainEarliest = "earliest"
+	// Their first pick becomes captain instead of them.
+	IdleCaptainPass = "pass"
+)
+
+var idleCaptainNames = []string{IdleCaptainHighest, IdleCaptainEarliest, IdleCaptainPass}
+
+// Longest pick time a mod can set.
+const MaxPickTime = 10 * time.Minute
+
+// Most time before the deadline captains are warned.
+const MaxPickWarning = 15 * time.Second
+
+func (mod *Mod) idleCaptain() string {
+	if mod.IdleCaptain == "" {
+		return IdleCaptainHighest
+	}
+	return mod.IdleCaptain
+}
+
+// How long before the deadline the captain whose turn it is gets warned.
+func (mod *Mod) pickWarning() time.Duration {
+	warning := (mod.PickTime / 3).Round(time.Second)
+	if warning > MaxPickWarning {
+		return MaxPickWarning
+	}
+	return warning
+}
+
+// What the warning tells the captain will happen.
+func (mod *Mod) idleCaptainConsequence() string {
+	switch mod.idleCaptain() {
+	case IdleCaptainEarliest:
+		return "the players who joined first will be picked for you"
+	case IdleCaptainPass:
+		return "your first pick becomes captain"
+	}
+	return "the best rated players left will be picked for you"
+}
+
+// Starts the timer of the turn that just began, if the mod has one.
+func (b *Bot) startPickTimer(g GameIdentifier) {
+	game := b.games[g]
+	mod := b.channels[g.Channel].Mods[g.Mod]
+	if mod.PickTime == 0 || !game.IsPickingTeams(mod) || len(game.Players) <= 1 {
+		game.PickDeadline = time.Time{}
+		return
+	}
+	game.PickDeadline = time.Now().Add(mod.PickTime)
+	b.runPickTimer(g, mod)
+}
+
+func pickTimerText(g GameIdentifier, captainName string, seconds int) string {
+	return fmt.Sprintf("**%s**: %s has `%d seconds` to pick", g.Mod, captainName, seconds)
+}
+
+// Shows the time left in the current turn until `PickDeadline` and acts for
+// the captain if it passes. Used directly to resume a timer that was
+// interrupted by a restart.
+func (b *Bot) runPickTimer(g GameIdentifier, mod *Mod) {
+	game := b.games[g]
+	deadline := game.PickDeadline
+	color, _ := game.PickTurn(mod)
+	captainName := game.team(color)[game.captain(color)].Name
+	seconds := int(time.Until(deadline).Round(time.Second).Seconds())
+	messageID, _ := b.messenger.Send(g.Channel, pickTimerText(g, captainName, seconds))
+
+	warned := time.Until(deadline) <= mod.pickWarning()
+	ticker := time.NewTicker(time.Second)
+	go func() {
+		defer ticker.Stop()
+		for range ticker.C {
+			if !b.tickPickTimer(g, game, mod, deadline, captainName, messageID, &warned) {
+				return
+			}
+		}
+	}()
+}
+
+// Updates the pick timer message of `game`. Returns false once the turn is over.
+func (b *Bot) tickPickTimer(g GameIdentifier, game *Game, mod *Mod, deadline time.Time, captainName string, messageID string, warned *bool) bool {
+	b.lock()
+	defer b.unlock()
+	seconds := int(time.Until(deadline).Round(time.Second).Seconds())
+	// The captain picked, or the game was finished, reset or disabled.
+	if b.games[g] != game || !game.IsPickingTeams(mod) || !game.PickDeadline.Equal(deadline) {
+		b.messenger.Edit(g.Channel, messageID, "~~"+pickTimerText(g, captainName, seconds)+"~~")
+		return false
+	}
+
+	switch {
+	case seconds <= 0:
+		b.messenger.Edit(g.Channel, messageID, fmt.Sprintf("**%s**: %s ran out of time", g.Mod, captainName))
+		b.expirePick(g, mod)
+		b.saveGame(g)
+		return false
+	case !*warned && time.Until(deadline) <= mod.pickWarning():
+		*warned = true
+		color, _ := game.PickTurn(mod)
+		b.messenger.Send(g.Channel, fmt.Sprintf("%s, pick within `%d seconds` or %s", mention(game.captain(color)), seconds, mod.idleCaptainConsequence()))
+		b.messenger.Edit(g.Channel, messageID, pickTimerText(g, captainName, seconds))
+	case seconds%countdownUpdateSeconds(mod.PickTime) == 0 || seconds < 5:
+		b.messenger.Edit(g.Channel, messageID, pickTimerText(g, captainName, seconds))
+	}
+	return true
+}
+
+// Acts for the captain whose turn ran out, the way the mod wants.
+func (b *Bot) expirePick(g GameIdentifier, mod *Mod) {
+	game := b.games[g]
+	color, picks := game.PickTurn(mod)
+	captain := game.captain(color)
+	team := game.team(color)
+	log.Printf("%s ran out of time to pick in %v", captain, g)
+
+	var message []string
+	// Passing the captaincy needs someone who was picked already.
+	if mod.idleCaptain() != IdleCaptainPass || firstPick(team, captain) == "" {
+		players := game.PlayersSortedByJoinTime()
+		if mod.idleCaptain() == IdleCaptainHighest {
+			score := b.scorer(g)
+			sort.SliceStable(players, func(i, j int) bool {
+				return score(players[i].Key) > score(players[j].Key)
+			})
+		}
+		var names []string
+		for _, player := range players[:picks] {
+			game.Pick(player.Key, color)
+			names = append(names, player.Value.Name)
+		}
+		message = append(message, fmt.Sprintf("%s didn't pick in time, picked %s for them", team[captain].Name, strings.Join(names, " and ")))
+	}
+	if mod.idleCaptain() == IdleCaptainPass {
+		next := firstPick(team, captain)
+		if color == Red {
+			game.RedCaptain = &next
+		} else {
+			game.BlueCaptain = &next
+		}
+		if len(message) == 0 {
+			message = append(message, fmt.Sprintf("%s didn't pick in time", team[captain].Name))
+		}
+		message = append(message, fmt.Sprintf("%s is captain for the **%s Team** now", mention(next), color))
+	}
+	message = append(message, strings.TrimSuffix(game.Teams(), "\n"))
+	b.announceTurn(g, message)
+}
+
+// Returns the user ID of the player `captain` picked first, or an empty string
+// if they didn't pick anyone yet.
+func firstPick(team map[string]*PlayerMetadata, captain string) string {
+	first := ""
+	for id, player := range team {
+		if id != captain && (first == "" || player.PickedOrder < team[first].PickedOrder) {
+			first = id
+		}
+	}
+	return first
+}
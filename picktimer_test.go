@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPickTimerRunsPerTurn(t *testing.T) {
+	tb := newTestBot(t, 8, "countdown 0", "pickorder snake", "picktime 5m")
+	tb.join(1, 8)
+
+	tests := []struct {
+		name    string
+		restart bool
+	}{
+		{"red picks their turn", true},
+		{"blue picks one of two", false},
+		{"blue picks the second", true},
+	}
+	for _, test := range tests {
+		game := tb.game()
+		deadline := game.PickDeadline
+		color, _ := game.PickTurn(tb.mod())
+		number := game.PlayersSortedByJoinTime()[0].Value.PickingNumber
+		tb.run(User{ID: game.captain(color)}, fmt.Sprintf(".p %d", number))
+		if restarted := !tb.game().PickDeadline.Equal(deadline); restarted != test.restart {
+			t.Errorf("%s: timer restarted %v, want %v", test.name, restarted, test.restart)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "math"
+
+const (
+	eloInitial = 1500
+	// How many points a player wins or loses at most in one match.
+	eloK = 32
+)
+
+// elo treats each team as a single player rated with the average rating of its
+// members. Everyone on a team wins or loses the same number of points.
+type elo struct{}
+
+func (elo) New() Rating {
+	return Rating{Value: eloInitial}
+}
+
+func (e elo) Update(red []*Rating, blue []*Rating, outcome Outcome) {
+	delta := eloK * (outcome.redScore() - e.WinProbability(red, blue))
+	for _, r := range red {
+		r.Value += delta
+	}
+	for _, r := range blue {
+		r.Value -= delta
+	}
+}
+
+func (elo) WinProbability(red []*Rating, blue []*Rating) float64 {
+	value := func(r *Rating) float64 { return r.Value }
+	return 1 / (1 + math.Pow(10, (meanRating(blue, value)-meanRating(red, value))/400))
+}
+
+func (elo) Score(r *Rating) float64 {
+	return r.Value
+}
+
+func (elo) SetScore(r *Rating, score float64) {
+	r.Value = score
+}
@@ -0,0 +1,127 @@
+package main
+
+import "math"
+
+const (
+	glickoInitial    = 1500
+	glickoDeviation  = 350
+	glickoVolatility = 0.06
+	// Converts between the Glicko and the Glicko-2 scale.
+	glickoScale = 173.7178
+	// Constrains how fast volatility changes.
+	glickoTau = 0.5
+	// Precision of the volatility iteration.
+	glickoEpsilon = 0.000001
+)
+
+// glicko2 implements Glicko-2 with every match as its own rating period. Each
+// player is rated against a composite opponent with the average rating and
+// deviation of the other team.
+type glicko2 struct{}
+
+func (glicko2) New() Rating {
+	return Rating{Value: glickoInitial, Deviation: glickoDeviation, Volatility: glickoVolatility}
+}
+
+// Returns the average rating and deviation of a team on the Glicko-2 scale.
+func glickoComposite(team []*Rating) (float64, float64) {
+	mu := meanRating(team, func(r *Rating) float64 { return (r.Value - glickoInitial) / glickoScale })
+	phiSquared := meanRating(team, func(r *Rating) float64 { return math.Pow(r.Deviation/glickoScale, 2) })
+	return mu, math.Sqrt(phiSquared)
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func glickoE(mu float64, opponentMu float64, opponentPhi float64) float64 {
+	return 1 / (1 + math.Exp(-glickoG(opponentPhi)*(mu-opponentMu)))
+}
+
+func (g glicko2) Update(red []*Rating, blue []*Rating, outcome Outcome) {
+	redMu, redPhi := glickoComposite(red)
+	blueMu, bluePhi := glickoComposite(blue)
+	for _, r := range red {
+		g.rate(r, []glickoGame{{blueMu, bluePhi, outcome.redScore()}})
+	}
+	for _, r := range blue {
+		g.rate(r, []glickoGame{{redMu, redPhi, 1 - outcome.redScore()}})
+	}
+}
+
+// glickoGame is a game of a rating period: the opponent on the Glicko-2 scale
+// and how much of it was won.
+type glickoGame struct {
+	mu    float64
+	phi   float64
+	score float64
+}
+
+// Rates the games `r` played in one rating period.
+func (glicko2) rate(r *Rating, games []glickoGame) {
+	mu := (r.Value - glickoInitial) / glickoScale
+	phi := r.Deviation / glickoScale
+	sigma := r.Volatility
+
+	var variance, improvement float64
+	for _, game := range games {
+		g := glickoG(game.phi)
+		e := glickoE(mu, game.mu, game.phi)
+		variance += g * g * e * (1 - e)
+		improvement += g * (game.score - e)
+	}
+	v := 1 / variance
+	delta := v * improvement
+
+	// Find the new volatility with the Illinois algorithm.
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		return ex*(delta*delta-phi*phi-v-ex)/(2*math.Pow(phi*phi+v+ex, 2)) - (x-a)/(glickoTau*glickoTau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	sigma = math.Exp(A / 2)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * improvement
+
+	r.Value = mu*glickoScale + glickoInitial
+	r.Deviation = phi * glickoScale
+	r.Volatility = sigma
+}
+
+func (glicko2) WinProbability(red []*Rating, blue []*Rating) float64 {
+	redMu, redPhi := glickoComposite(red)
+	blueMu, bluePhi := glickoComposite(blue)
+	return glickoE(redMu, blueMu, math.Sqrt(redPhi*redPhi+bluePhi*bluePhi))
+}
+
+func (glicko2) Score(r *Rating) float64 {
+	return r.Value
+}
+
+func (glicko2) SetScore(r *Rating, score float64) {
+	r.Value = score
+}
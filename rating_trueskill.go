@@ -0,0 +1,127 @@
+package main
+
+import "math"
+
+// TrueSkill doesn't depend on its scale, so the usual parameters are
+// multiplied by 60 to make ratings look like Elo ratings.
+const (
+	trueSkillMu    = 1500
+	trueSkillSigma = trueSkillMu / 3
+	// Skill difference that gives the better team a 76% chance of winning.
+	trueSkillBeta = trueSkillSigma / 2
+	// Added to the deviation before every match so that ratings never freeze.
+	trueSkillTau = trueSkillSigma / 100
+	// How likely two equally skilled teams are to draw.
+	trueSkillDrawProbability = 0.1
+)
+
+// trueSkill is a two team version of TrueSkill. Team skill is the sum of the
+// skills of its members and every player is updated by how uncertain their
+// own rating is.
+type trueSkill struct{}
+
+func (trueSkill) New() Rating {
+	return Rating{Value: trueSkillMu, Deviation: trueSkillSigma}
+}
+
+func normalPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+func normalCDF(x float64) float64 {
+	return math.Erfc(-x/math.Sqrt2) / 2
+}
+
+func inverseNormalCDF(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// Mean and variance correction for a win by a performance difference of `t`
+// with a draw margin of `epsilon`.
+func trueSkillWin(t float64, epsilon float64) (float64, float64) {
+	denominator := normalCDF(t - epsilon)
+	if denominator < 1e-160 {
+		return epsilon - t, 1
+	}
+	v := normalPDF(t-epsilon) / denominator
+	return v, v * (v + t - epsilon)
+}
+
+// Mean and variance correction for a draw.
+func trueSkillDraw(t float64, epsilon float64) (float64, float64) {
+	abs := math.Abs(t)
+	a, b := epsilon-abs, -epsilon-abs
+	denominator := normalCDF(a) - normalCDF(b)
+	if denominator < 1e-160 {
+		return 0, 1
+	}
+	v := (normalPDF(b) - normalPDF(a)) / denominator
+	w := v*v + (a*normalPDF(a)-b*normalPDF(b))/denominator
+	if t < 0 {
+		v = -v
+	}
+	return v, w
+}
+
+func (trueSkill) Update(red []*Rating, blue []*Rating, outcome Outcome) {
+	for _, r := range append(append([]*Rating{}, red...), blue...) {
+		r.Deviation = math.Sqrt(r.Deviation*r.Deviation + trueSkillTau*trueSkillTau)
+	}
+	c := trueSkillC(red, blue)
+	players := float64(len(red) + len(blue))
+	epsilon := inverseNormalCDF((trueSkillDrawProbability+1)/2) * math.Sqrt(players) * trueSkillBeta / c
+
+	winners, losers := red, blue
+	if outcome == OutcomeBlue {
+		winners, losers = blue, red
+	}
+	t := (teamSkill(winners) - teamSkill(losers)) / c
+	var v, w float64
+	if outcome == OutcomeDraw {
+		v, w = trueSkillDraw(t, epsilon)
+	} else {
+		v, w = trueSkillWin(t, epsilon)
+	}
+	adjust := func(team []*Rating, sign float64) {
+		for _, r := range team {
+			variance := r.Deviation * r.Deviation
+			r.Value += sign * variance / c * v
+			r.Deviation = math.Sqrt(variance * math.Max(1-variance/(c*c)*w, 0.0001))
+		}
+	}
+	adjust(winners, 1)
+	adjust(losers, -1)
+}
+
+func teamSkill(team []*Rating) float64 {
+	var sum float64
+	for _, r := range team {
+		sum += r.Value
+	}
+	return sum
+}
+
+// Standard deviation of the performance difference between the teams.
+func trueSkillC(red []*Rating, blue []*Rating) float64 {
+	variance := float64(len(red)+len(blue)) * trueSkillBeta * trueSkillBeta
+	for _, r := range append(append([]*Rating{}, red...), blue...) {
+		variance += r.Deviation * r.Deviation
+	}
+	return math.Sqrt(variance)
+}
+
+func (trueSkill) WinProbability(red []*Rating, blue []*Rating) float64 {
+	return normalCDF((teamSkill(red) - teamSkill(blue)) / trueSkillC(red, blue))
+}
+
+// Score is a conservative estimate of the skill: the player is very likely
+// at least this good.
+func (trueSkill) Score(r *Rating) float64 {
+	return r.Value - 3*r.Deviation
+}
+
+// SetScore keeps the deviation and moves the skill so that the conservative
+// estimate is `score`.
+func (trueSkill) SetScore(r *Rating, score float64) {
+	r.Value = score + 3*r.Deviation
+}
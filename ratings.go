@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const DefaultRatingSystem = "elo"
+
+// Rating is the skill of a player in a mod as estimated by a RatingSystem.
+// Which fields are used depends on the system.
+type Rating struct {
+	Value float64
+	// How uncertain Value is.
+	Deviation float64
+	// How erratic the player's results are. Only used by Glicko-2.
+	Volatility float64
+	// Number of rated matches.
+	Games int
+}
+
+// RatingSystem rates players from the results of their matches.
+type RatingSystem interface {
+	// New returns the rating of a player who hasn't played yet.
+	New() Rating
+	// Update adjusts the ratings of both teams after a match.
+	Update(red []*Rating, blue []*Rating, outcome Outcome)
+	// WinProbability returns the chance of red beating blue.
+	WinProbability(red []*Rating, blue []*Rating) float64
+	// Score is the single number players are ranked by.
+	Score(r *Rating) float64
+	// SetScore changes a rating so that its Score is `score`.
+	SetScore(r *Rating, score float64)
+}
+
+// RatingAdjustment is a rating an admin gave a player with .setrating. Their
+// matches from then on are rated starting from it.
+type RatingAdjustment struct {
+	Player string
+	Score  float64
+	Time   time.Time
+}
+
+var (
+	ratingSystems = map[string]RatingSystem{
+		"elo":       elo{},
+		"glicko2":   glicko2{},
+		"trueskill": trueSkill{},
+	}
+	// Names of the rating systems in the order they are documented.
+	ratingSystemNames = []string{"elo", "glicko2", "trueskill"}
+)
+
+func (mod *Mod) ratingSystem() RatingSystem {
+	if system, ok := ratingSystems[mod.RatingSystem]; ok {
+		return system
+	}
+	return ratingSystems[DefaultRatingSystem]
+}
+
+func (mod *Mod) ratingSystemName() string {
+	if _, ok := ratingSystems[mod.RatingSystem]; ok {
+		return mod.RatingSystem
+	}
+	return DefaultRatingSystem
+}
+
+// How much a match counts for red: 1 for a win, 0.5 for a draw, 0 for a loss.
+func (o Outcome) redScore() float64 {
+	switch o {
+	case OutcomeRed:
+		return 1
+	case OutcomeDraw:
+		return 0.5
+	}
+	return 0
+}
+
+func meanRating(team []*Rating, value func(r *Rating) float64) float64 {
+	if len(team) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, r := range team {
+		sum += value(r)
+	}
+	return sum / float64(len(team))
+}
+
+// Returns the rating adjustments of a mod made since the ratings were last
+// reset, oldest first.
+func (mod *Mod) countedAdjustments() []RatingAdjustment {
+	var adjustments []RatingAdjustment
+	for _, adjustment := range mod.RatingAdjustments {
+		if !adjustment.Time.Before(mod.RatingsSince) {
+			adjustments = append(adjustments, adjustment)
+		}
+	}
+	return adjustments
+}
+
+// Rates the counted matches of a mod with a confirmed result, oldest first,
+// and applies the rating adjustments made before each match was picked.
+// `rated` is called after each match if it isn't nil.
+func (b *Bot) rateMatches(g GameIdentifier, rated func(match *Match, ratings map[string]*Rating)) map[string]*Rating {
+	mod := b.channels[g.Channel].Mods[g.Mod]
+	system := mod.ratingSystem()
+	ratings := make(map[string]*Rating)
+	ratingOf := func(id string) *Rating {
+		if _, ok := ratings[id]; !ok {
+			rating := system.New()
+			ratings[id] = &rating
+		}
+		return ratings[id]
+	}
+	team := func(players []MatchPlayer) []*Rating {
+		var team []*Rating
+		for _, player := range players {
+			rating := ratingOf(player.ID)
+			rating.Games++
+			team = append(team, rating)
+		}
+		return team
+	}
+	adjustments := mod.countedAdjustments()
+	adjust := func(before time.Time) {
+		for len(adjustments) > 0 && adjustments[0].Time.Before(before) {
+			system.SetScore(ratingOf(adjustments[0].Player), adjustments[0].Score)
+			adjustments = adjustments[1:]
+		}
+	}
+	for _, match := range b.countedMatches(g) {
+		if outcome := match.Outcome(); outcome != OutcomeNone {
+			adjust(match.PickedTime)
+			system.Update(team(match.Red), team(match.Blue), outcome)
+			if rated != nil {
+				rated(match, ratings)
+			}
+		}
+	}
+	for _, adjustment := range adjustments {
+		system.SetScore(ratingOf(adjustment.Player), adjustment.Score)
+	}
+	return ratings
+}
+
+// Rates all matches of a mod again and stores the ratings.
+func (b *Bot) recomputeRatings(g GameIdentifier) {
+	if channel, ok := b.channels[g.Channel]; !ok || channel.Mods[g.Mod] == nil {
+		return
+	}
+	ratings := b.rateMatches(g, nil)
+	b.ratings[g] = ratings
+	if err := b.storage.SaveRatings(g, ratings); err != nil {
+		log.Printf("Failed to save ratings of %v: %s", g, err)
+	}
+}
+
+// Returns the rating of a player in a mod, or a new one if they have none yet.
+func (b *Bot) rating(g GameIdentifier, userID string) *Rating {
+	if rating, ok := b.ratings[g][userID]; ok {
+		return rating
+	}
+	rating := b.channels[g.Channel].Mods[g.Mod].ratingSystem().New()
+	return &rating
+}
+
+// Bot commands
+
+// Setrating gives a player a rating in a mod. It stays in the history of the
+// mod so that rating its matches again starts from it too.
+func (b *Bot) Setrating(ctx *Context, user User, modName string, score int) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, modName)
+	if gameID == nil || mod == nil {
+		return
+	}
+	id, name := user.ID, user.DisplayName()
+	if id == "" {
+		record := findRecord(playerRecords(b.playedMatches(*gameID)), user)
+		if record == nil {
+			ctx.Fail(fmt.Sprintf("%s didn't play **%s** yet, mention them to set their rating", name, modName))
+			return
+		}
+		id, name = record.ID, record.Name
+	}
+	if name == "" {
+		name = mention(id)
+	}
+	mod.RatingAdjustments = append(mod.RatingAdjustments, RatingAdjustment{Player: id, Score: float64(score), Time: time.Now()})
+	if !b.saveChannel(ctx.ChannelID) {
+		return
+	}
+	b.recomputeRatings(*gameID)
+	ctx.Reply(fmt.Sprintf("Rating of **%s** in **%s** set to %d", name, modName, score))
+}
+
+func (b *Bot) Resetratings(ctx *Context, modName string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, modName)
+	if gameID == nil || mod == nil {
+		return
+	}
+	mod.RatingsSince = time.Now()
+	if !b.saveChannel(ctx.ChannelID) {
+		return
+	}
+	b.recomputeRatings(*gameID)
+	ctx.Reply(fmt.Sprintf("Ratings of **%s** were reset. Only matches from now on count.", modName))
+}
+
+// Recomputeratings rates all matches of one or every mod on the channel again.
+func (b *Bot) Recomputeratings(ctx *Context, modName string) {
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		for name := range c.Mods {
+			if modName == "" || name == modName {
+				b.recomputeRatings(GameIdentifier{ctx.ChannelID, name})
+			}
+		}
+		ctx.Ack()
+	}
+}
+
+// RecomputeAllRatings rates all matches of every mod again.
+func (b *Bot) RecomputeAllRatings() {
+	b.lock()
+	defer b.unlock()
+	for channelID, c := range b.channels {
+		for name := range c.Mods {
+			b.recomputeRatings(GameIdentifier{channelID, name})
+		}
+	}
+}
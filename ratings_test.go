@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSetrating(t *testing.T) {
+	tb, match := playReportedMatch(t)
+	tb.run(User{ID: match.BlueCaptain}, ".confirm 1")
+	g := GameIdentifier{testChannel, "ctf"}
+	score := func(id string) float64 {
+		return tb.mod().ratingSystem().Score(tb.rating(g, id))
+	}
+	if score(match.RedCaptain) <= eloInitial {
+		t.Fatalf("red captain rated %.0f after a win", score(match.RedCaptain))
+	}
+
+	name := match.Red[0].Name
+	tb.admin(".setrating " + name + " ctf 1400")
+	tb.admin(".setrating <@" + testUser(9).ID + "> ctf 1700")
+	if got := score(match.RedCaptain); got != 1400 {
+		t.Errorf("%s rated %.0f, want 1400", name, got)
+	}
+	if got := score(testUser(9).ID); got != 1700 {
+		t.Errorf("Player9 rated %.0f, want 1700", got)
+	}
+
+	// Rating the matches again, with another system, keeps what was set.
+	tb.admin(".setmod ctf rating glicko2")
+	if tb.mod().RatingSystem != "glicko2" {
+		t.Fatalf("rating system = %q, want glicko2", tb.mod().RatingSystem)
+	}
+	if got := score(match.RedCaptain); got != 1400 {
+		t.Errorf("%s rated %.0f with glicko2, want 1400", name, got)
+	}
+
+	tb.admin(".setrating nobody ctf 1500")
+	tb.expectSent("nobody didn't play **ctf** yet")
+}
+
+func TestRatingSystems(t *testing.T) {
+	for _, name := range ratingSystemNames {
+		system := ratingSystems[name]
+		t.Run(name, func(t *testing.T) {
+			newTeam := func() []*Rating {
+				var team []*Rating
+				for i := 0; i < 2; i++ {
+					rating := system.New()
+					team = append(team, &rating)
+				}
+				return team
+			}
+			red, blue := newTeam(), newTeam()
+			if p := system.WinProbability(red, blue); math.Abs(p-0.5) > 1e-9 {
+				t.Errorf("even match: win probability %v, want 0.5", p)
+			}
+
+			initial := system.New()
+			system.Update(red, blue, OutcomeRed)
+			gain, loss := red[0].Value-initial.Value, initial.Value-blue[0].Value
+			if gain <= 0 || math.Abs(gain-loss) > 1e-6 {
+				t.Errorf("winner gained %v and loser lost %v, want the same positive amount", gain, loss)
+			}
+			if initial.Deviation > 0 && (red[0].Deviation >= initial.Deviation || blue[0].Deviation >= initial.Deviation) {
+				t.Errorf("deviation %v and %v after a match, want less than %v", red[0].Deviation, blue[0].Deviation, initial.Deviation)
+			}
+			if p := system.WinProbability(red, blue); p <= 0.5 {
+				t.Errorf("winner's win probability %v, want more than 0.5", p)
+			}
+
+			red, blue = newTeam(), newTeam()
+			system.Update(red, blue, OutcomeDraw)
+			if math.Abs(red[0].Value-initial.Value) > 1e-6 || math.Abs(blue[0].Value-initial.Value) > 1e-6 {
+				t.Errorf("draw of equals moved ratings to %v and %v", red[0].Value, blue[0].Value)
+			}
+
+			system.SetScore(red[0], 1234)
+			if score := system.Score(red[0]); math.Abs(score-1234) > 1e-9 {
+				t.Errorf("score after SetScore(1234) = %v", score)
+			}
+		})
+	}
+}
+
+func TestElo(t *testing.T) {
+	tests := []struct {
+		red, blue float64
+		outcome   Outcome
+		want      float64
+	}{
+		{1500, 1500, OutcomeRed, 1516},
+		{1500, 1500, OutcomeBlue, 1484},
+		{1900, 1500, OutcomeRed, 1900 + 32.0/11},
+		{1500, 1900, OutcomeRed, 1500 + 320.0/11},
+	}
+	for _, test := range tests {
+		red, blue := &Rating{Value: test.red}, &Rating{Value: test.blue}
+		elo{}.Update([]*Rating{red}, []*Rating{blue}, test.outcome)
+		if math.Abs(red.Value-test.want) > 1e-9 {
+			t.Errorf("%v vs %v, %v: red rated %v, want %v", test.red, test.blue, test.outcome, red.Value, test.want)
+		}
+	}
+}
+
+// The example of Glickman's "Example of the Glicko-2 system".
+func TestGlicko2Example(t *testing.T) {
+	r := &Rating{Value: 1500, Deviation: 200, Volatility: 0.06}
+	var games []glickoGame
+	for _, opponent := range []struct{ rating, deviation, score float64 }{
+		{1400, 30, 1},
+		{1550, 100, 0},
+		{1700, 300, 0},
+	} {
+		games = append(games, glickoGame{(opponent.rating - glickoInitial) / glickoScale, opponent.deviation / glickoScale, opponent.score})
+	}
+	glicko2{}.rate(r, games)
+	if math.Abs(r.Value-1464.06) > 0.01 || math.Abs(r.Deviation-151.52) > 0.01 || math.Abs(r.Volatility-0.05999) > 0.00001 {
+		t.Errorf("rated %.2f, deviation %.2f, volatility %.5f, want 1464.06, 151.52, 0.05999", r.Value, r.Deviation, r.Volatility)
+	}
+}
+
+func TestTrueSkillUncertaintyShrinks(t *testing.T) {
+	red, blue := trueSkill{}.New(), trueSkill{}.New()
+	previous := red.Deviation
+	for i := 0; i < 10; i++ {
+		trueSkill{}.Update([]*Rating{&red}, []*Rating{&blue}, OutcomeRed)
+		if red.Deviation >= previous {
+			t.Fatalf("deviation %v after %d wins, want less than %v", red.Deviation, i+1, previous)
+		}
+		previous = red.Deviation
+	}
+	if score := (trueSkill{}).Score(&red); score <= (trueSkill{}).Score(&blue) {
+		t.Errorf("winner scored %v, loser %v", score, (trueSkill{}).Score(&blue))
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reaction and button players confirm a ready check with, besides .ready.
+const (
+	ReadyEmoji    = "✅"
+	ReadyButtonID = "ready"
+)
+
+// Longest ready check a mod can set.
+const MaxReadyCheck = 10 * time.Minute
+
+// Lists the players of a game who are ready and those who aren't yet.
+func (game *Game) readiness() ([]string, []string) {
+	var ready, waiting []string
+	for _, player := range game.PlayersSortedByJoinTime() {
+		if player.Value.Ready {
+			ready = append(ready, player.Value.Name)
+		} else {
+			waiting = append(waiting, player.Value.Name)
+		}
+	}
+	return ready, waiting
+}
+
+// Ends the ready check of a game. Confirmations only count for the check
+// they were given in, so that a player who confirmed long ago isn't taken
+// for ready when the game fills again.
+func (game *Game) endReadyCheck() {
+	game.ReadyDeadline = time.Time{}
+	for _, player := range game.Players {
+		player.Ready = false
+	}
+}
+
+// Returns the ID of the ready check message of a game: its Discord ID once it
+// was sent, its queued ID before that. Empty if the message wasn't sent before
+// the bot restarted.
+func (game *Game) readyMessageID() string {
+	if game.ReadyMessageID != "" {
+		return game.ReadyMessageID
+	}
+	return game.queuedReadyMessageID
+}
+
+// Edits the ready check message of a game if it is known.
+func (b *Bot) editReadyCheck(g GameIdentifier, game *Game, content string) {
+	if messageID := game.readyMessageID(); messageID != "" {
+		b.messenger.Edit(g.Channel, messageID, content)
+	}
+}
+
+func readyCheckText(g GameIdentifier, game *Game, seconds int) string {
+	ready, waiting := game.readiness()
+	sort.Strings(ready)
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "**%s** ready check: react with %s, press **Ready** or type `%sready` within `%d seconds`", g.Mod, ReadyEmoji, CommandPrefix, seconds)
+	if len(ready) > 0 {
+		fmt.Fprintf(&builder, "\nReady: %s", strings.Join(ready, " :small_orange_diamond: "))
+	}
+	if len(waiting) > 0 {
+		fmt.Fprintf(&builder, "\nWaiting for: %s", strings.Join(waiting, " :small_orange_diamond: "))
+	}
+	return builder.String()
+}
+
+// Asks the players of a game that just filled to confirm they are ready.
+// Players who confirmed the check that is still running, before someone left
+// and the game filled again, stay ready.
+func (b *Bot) startReadyCheck(g GameIdentifier, mod *Mod) {
+	game := b.games[g]
+	if game.ReadyDeadline.IsZero() {
+		game.endReadyCheck()
+	} else {
+		// The game filled again before the running check noticed someone left.
+		b.editReadyCheck(g, game, fmt.Sprintf("~~**%s** ready check~~", g.Mod))
+		game.ReadyDeadline = time.Time{}
+	}
+	if _, waiting := game.readiness(); len(waiting) == 0 {
+		b.formTeams(g, mod)
+		return
+	}
+	game.ReadyDeadline = time.Now().Add(mod.ReadyCheck)
+	// The outbox sends the message later and reports failures itself.
+	messageID, _ := b.messenger.SendButton(g.Channel, readyCheckText(g, game, int(mod.ReadyCheck.Seconds())), "Ready", ReadyButtonID)
+	game.ReadyMessageID, game.queuedReadyMessageID = "", messageID
+	b.messenger.React(g.Channel, messageID, ReadyEmoji)
+	b.runReadyCheck(g, mod)
+}
+
+// Counts down the ready check of a game until `ReadyDeadline`, then removes
+// the players who didn't confirm. Used directly to resume a ready check that
+// was interrupted by a restart.
+func (b *Bot) runReadyCheck(g GameIdentifier, mod *Mod) {
+	game := b.games[g]
+	deadline := game.ReadyDeadline
+	ticker := time.NewTicker(time.Second)
+	go func() {
+		defer ticker.Stop()
+		for range ticker.C {
+			if !b.tickReadyCheck(g, game, mod, deadline) {
+				return
+			}
+		}
+	}()
+}
+
+// Updates the ready check message of `game`. Returns false once the ready
+// check is over.
+func (b *Bot) tickReadyCheck(g GameIdentifier, game *Game, mod *Mod, deadline time.Time) bool {
+	b.lock()
+	defer b.unlock()
+	// Everyone was ready, or the game was reset or disabled.
+	if b.games[g] != game || !game.ReadyDeadline.Equal(deadline) {
+		return false
+	}
+	// Keep the Discord ID of the message once it is sent, so that reactions
+	// and edits still find it after a restart.
+	if queued := game.queuedReadyMessageID; game.ReadyMessageID == "" && queued != "" {
+		if messageID := b.outbox.resolve(queued); messageID != queued {
+			game.ReadyMessageID = messageID
+			b.saveGame(g)
+		}
+	}
+	seconds := int(time.Until(deadline).Round(time.Second).Seconds())
+	if !game.IsFull(mod) {
+		b.editReadyCheck(g, game, "~~"+readyCheckText(g, game, seconds)+"~~")
+		game.endReadyCheck()
+		b.saveGame(g)
+		return false
+	}
+
+	if seconds <= 0 {
+		_, waiting := game.readiness()
+		b.editReadyCheck(g, game, fmt.Sprintf("**%s** ready check is over", g.Mod))
+		var mentions []string
+		for id, player := range game.Players {
+			if !player.Ready {
+				mentions = append(mentions, mention(id))
+				delete(game.Players, id)
+			}
+		}
+		sort.Strings(mentions)
+		log.Printf("%s weren't ready for %v", strings.Join(waiting, ", "), g)
+		game.endReadyCheck()
+		b.messenger.Send(g.Channel, fmt.Sprintf("%s removed from **%s** because they weren't ready [%d / %d]",
+			strings.Join(mentions, " "), g.Mod, len(game.Players), mod.MaxPlayers))
+		b.saveGame(g)
+		b.refill(g)
+		return false
+	}
+	if seconds%countdownUpdateSeconds(mod.ReadyCheck) == 0 || seconds < 5 {
+		b.editReadyCheck(g, game, readyCheckText(g, game, seconds))
+	}
+	return true
+}
+
+// Treats a ready reaction as .ready if a player added it to their ready check.
+// Reactions of anyone else are ignored.
+func (b *Bot) readyReaction(channelID string, messageID string, user User) {
+	b.lock()
+	readyCheck := false
+	for g, game := range b.games {
+		if g.Channel == channelID && b.outbox.resolve(game.readyMessageID()) == messageID && !game.ReadyDeadline.IsZero() && game.HasPlayer(user.ID) {
+			readyCheck = true
+		}
+	}
+	b.unlock()
+	if readyCheck {
+		ctx := NewContext(b.messenger, channelID, user)
+		ctx.MessageID = messageID
+		b.execute(ctx, commandsByName["ready"], nil)
+	}
+}
+
+// Bot commands
+
+// Ready confirms the ready check of every filled game the player is in.
+func (b *Bot) Ready(ctx *Context) {
+	ready := false
+	for name, mod := range b.channels[ctx.ChannelID].Mods {
+		g := GameIdentifier{ctx.ChannelID, name}
+		game, ok := b.games[g]
+		if !ok || game.ReadyDeadline.IsZero() || !game.HasPlayer(ctx.User.ID) {
+			continue
+		}
+		ready = true
+		game.Players[ctx.User.ID].Ready = true
+		if _, waiting := game.readiness(); len(waiting) > 0 {
+			b.editReadyCheck(g, game, readyCheckText(g, game, int(time.Until(game.ReadyDeadline).Round(time.Second).Seconds())))
+		} else {
+			b.editReadyCheck(g, game, fmt.Sprintf("**%s** ready check: everyone is ready", g.Mod))
+			game.endReadyCheck()
+			b.formTeams(g, mod)
+		}
+		b.saveGame(g)
+	}
+	if !ready {
+		ctx.Fail("You aren't in a game that is checking who is ready")
+		return
+	}
+	ctx.Ack()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadyCheck(t *testing.T) {
+	tb := newTestBot(t, 4, "readycheck 1m", "countdown 0")
+	tb.join(1, 5)
+	tb.expectSent("**ctf** ready check")
+	tb.run(testUser(1), ".ready")
+
+	// Player5 takes the place of Player2, Player1 doesn't have to confirm again.
+	tb.run(testUser(2), ".l ctf")
+	game := tb.game()
+	if !game.HasPlayer(testUser(5).ID) || game.ReadyDeadline.IsZero() {
+		t.Fatal("Player5 should have moved up into a new ready check")
+	}
+	if !game.Players[testUser(1).ID].Ready || game.Players[testUser(5).ID].Ready {
+		t.Errorf("only Player1 should be ready")
+	}
+
+	// Reacting to the ready check message counts as .ready.
+	messageID := tb.outbox.resolve(game.readyMessageID())
+	for _, i := range []int{3, 4, 5} {
+		tb.readyReaction(testChannel, messageID, testUser(i))
+	}
+	if !tb.game().IsPickingTeams(tb.mod()) {
+		t.Errorf("captains should be picked once everyone is ready")
+	}
+}
+
+func TestReadyCheckAfterReset(t *testing.T) {
+	tb := newTestBot(t, 4, "readycheck 1m", "countdown 0")
+	tb.join(1, 4)
+	for i := 1; i <= 4; i++ {
+		tb.run(testUser(i), ".ready")
+	}
+	if !tb.game().IsPickingTeams(tb.mod()) {
+		t.Fatal("captains should be picked once everyone is ready")
+	}
+
+	// Confirmations of the finished check don't count for the next one.
+	tb.admin(".reset ctf")
+	game := tb.game()
+	if game.ReadyDeadline.IsZero() {
+		t.Fatal("the reset game should check who is ready again")
+	}
+	for id, player := range game.Players {
+		if player.Ready {
+			t.Errorf("%s is still ready from the last check", id)
+		}
+	}
+}
+
+func TestReadyCheckAfterRestart(t *testing.T) {
+	tb := newTestBot(t, 4, "readycheck 1m", "countdown 0")
+	tb.join(1, 4)
+	restarted := tb.restart()
+	if id := restarted.game().ReadyMessageID; strings.HasPrefix(id, queuedIDPrefix) {
+		t.Fatalf("queued ID %s was saved as the ready check message", id)
+	}
+	for i := 1; i <= 4; i++ {
+		restarted.run(testUser(i), ".ready")
+	}
+	if !restarted.game().IsPickingTeams(restarted.mod()) {
+		t.Errorf("captains should be picked once everyone is ready")
+	}
+}
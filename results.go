@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Outcome int
+
+const (
+	OutcomeNone Outcome = iota
+	OutcomeRed
+	OutcomeBlue
+	OutcomeDraw
+)
+
+// Names of the outcomes that can be reported.
+var outcomeNames = []string{"red", "blue", "draw"}
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeRed:
+		return "Red won"
+	case OutcomeBlue:
+		return "Blue won"
+	case OutcomeDraw:
+		return "Draw"
+	}
+	return "No result"
+}
+
+func parseOutcome(name string) Outcome {
+	for i, outcomeName := range outcomeNames {
+		if strings.EqualFold(name, outcomeName) {
+			return OutcomeRed + Outcome(i)
+		}
+	}
+	return OutcomeNone
+}
+
+type ResultStatus int
+
+const (
+	// Reported by a captain, waiting for the other one to confirm.
+	ResultReported ResultStatus = iota
+	ResultConfirmed
+	// The captains disagree. A referee or admin has to settle it.
+	ResultContested
+)
+
+// MatchResult is the outcome of a match as far as it's known.
+type MatchResult struct {
+	Outcome Outcome
+	// Optional, red first, e.g. "3-1".
+	Score string
+	// Who reported the result last.
+	ReportedBy string
+	Status     ResultStatus
+	Time       time.Time
+}
+
+func (r *MatchResult) String() string {
+	result := r.Outcome.String()
+	if r.Score != "" {
+		result += " " + r.Score
+	}
+	switch r.Status {
+	case ResultReported:
+		return result + " (waiting for confirmation)"
+	case ResultContested:
+		return result + " (contested)"
+	}
+	return result
+}
+
+var scoreRegexp = regexp.MustCompile(`^(\d+)[-:](\d+)$`)
+
+// Normalizes a score such as 3:1 to 3-1. Returns false if it isn't a score.
+func parseScore(score string) (string, bool) {
+	match := scoreRegexp.FindStringSubmatch(score)
+	if match == nil {
+		return "", false
+	}
+	return match[1] + "-" + match[2], true
+}
+
+// Outcome returns the confirmed outcome of the match, or OutcomeNone if there
+// is none or the match was voided.
+func (match *Match) Outcome() Outcome {
+	if match.Voided || match.Result == nil || match.Result.Status != ResultConfirmed {
+		return OutcomeNone
+	}
+	return match.Result.Outcome
+}
+
+func (match *Match) isCaptain(userID string) bool {
+	return userID == match.RedCaptain || userID == match.BlueCaptain
+}
+
+func (match *Match) otherCaptain(userID string) string {
+	if userID == match.RedCaptain {
+		return match.BlueCaptain
+	}
+	return match.RedCaptain
+}
+
+// Finds a match of the channel by number, e.g. 12 or #12, or the last match of
+// a mod. Tells the user and returns nil if there is no such match.
+func (b *Bot) findMatch(ctx *Context, ref string) *Match {
+	if id, err := strconv.Atoi(strings.TrimPrefix(ref, "#")); err == nil {
+		for _, match := range b.matches {
+			if match.ID == id && match.Channel == ctx.ChannelID {
+				return match
+			}
+		}
+		ctx.Fail(fmt.Sprintf("There is no match #%d on this channel", id))
+		return nil
+	}
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		if _, ok := c.Mods[ref]; ok {
+			if matches := b.lastMatches(ctx.ChannelID, ref, 0, 1); len(matches) > 0 {
+				return matches[0]
+			}
+			ctx.Fail(fmt.Sprintf("No **%s** match was played yet", ref))
+			return nil
+		}
+	}
+	ctx.Fail(fmt.Sprintf("`%s` is neither a match number nor a mod", ref))
+	return nil
+}
+
+// Rates the mod of a match again after its result changed. Results can
+// change long after a match, so all of its matches are rated again.
+func (b *Bot) resultChanged(match *Match) {
+	b.recomputeRatings(GameIdentifier{match.Channel, match.Mod})
+}
+
+// Bot commands
+
+// Report records the outcome of a match. Results reported by a captain have to
+// be confirmed by the other one, results reported by referees count right away.
+func (b *Bot) Report(ctx *Context, ref string, outcome Outcome, score string) {
+	match := b.findMatch(ctx, ref)
+	if match == nil {
+		return
+	}
+	if match.Voided {
+		ctx.Fail(fmt.Sprintf("Match #%d was voided", match.ID))
+		return
+	}
+	referee := b.hasPermission(ctx, PermissionReferee)
+	captain := match.isCaptain(ctx.User.ID)
+	if !captain && !referee {
+		ctx.Fail(fmt.Sprintf("Only the captains of match #%d and referees can report its result", match.ID))
+		return
+	}
+	previous := match.Result
+	if previous != nil && previous.Status == ResultConfirmed {
+		ctx.Fail(fmt.Sprintf("The result of match #%d was already confirmed. Admins can change it with `%ssetresult`.", match.ID, CommandPrefix))
+		return
+	}
+
+	result := &MatchResult{Outcome: outcome, Score: score, ReportedBy: ctx.User.ID, Status: ResultReported, Time: time.Now()}
+	reportedByOtherCaptain := previous != nil && captain && previous.ReportedBy == match.otherCaptain(ctx.User.ID)
+	switch {
+	case referee:
+		result.Status = ResultConfirmed
+	case reportedByOtherCaptain && previous.Outcome == outcome && (score == "" || score == previous.Score):
+		result.Status = ResultConfirmed
+		result.Score = previous.Score
+	case reportedByOtherCaptain:
+		result.Status = ResultContested
+	}
+	match.Result = result
+	b.saveMatch(match)
+	if result.Status == ResultConfirmed {
+		b.resultChanged(match)
+	}
+
+	switch result.Status {
+	case ResultConfirmed:
+		ctx.Reply(fmt.Sprintf("Match **#%d**: %s", match.ID, result))
+	case ResultContested:
+		ctx.Reply(fmt.Sprintf("The captains of match **#%d** disagree on its result. A referee has to report it.", match.ID))
+	default:
+		ctx.Reply(fmt.Sprintf("Match **#%d**: %s\n%s, confirm with `%sconfirm %d` or contest with `%scontest %d`",
+			match.ID, result, mention(match.otherCaptain(ctx.User.ID)), CommandPrefix, match.ID, CommandPrefix, match.ID))
+	}
+}
+
+// Confirm accepts the result reported by the other captain.
+func (b *Bot) Confirm(ctx *Context, ref string) {
+	match := b.pendingResult(ctx, ref)
+	if match == nil {
+		return
+	}
+	match.Result.Status = ResultConfirmed
+	b.saveMatch(match)
+	b.resultChanged(match)
+	ctx.Reply(fmt.Sprintf("Match **#%d**: %s", match.ID, match.Result))
+}
+
+// Contest rejects the result reported by the other captain.
+func (b *Bot) Contest(ctx *Context, ref string) {
+	match := b.pendingResult(ctx, ref)
+	if match == nil {
+		return
+	}
+	match.Result.Status = ResultContested
+	b.saveMatch(match)
+	ctx.Reply(fmt.Sprintf("The result of match **#%d** is contested. A referee has to report it.", match.ID))
+}
+
+// Finds a match whose reported result the user may confirm or contest.
+func (b *Bot) pendingResult(ctx *Context, ref string) *Match {
+	match := b.findMatch(ctx, ref)
+	if match == nil {
+		return nil
+	}
+	if match.Voided || match.Result == nil || match.Result.Status != ResultReported {
+		ctx.Fail(fmt.Sprintf("No result of match #%d is waiting for confirmation", match.ID))
+		return nil
+	}
+	if ctx.User.ID != match.otherCaptain(match.Result.ReportedBy) && !b.hasPermission(ctx, PermissionReferee) {
+		ctx.Fail(fmt.Sprintf("Only %s or a referee can confirm the result of match #%d", mention(match.otherCaptain(match.Result.ReportedBy)), match.ID))
+		return nil
+	}
+	return match
+}
+
+// Setresult overrides the result of a match.
+func (b *Bot) Setresult(ctx *Context, ref string, outcome Outcome, score string) {
+	match := b.findMatch(ctx, ref)
+	if match == nil {
+		return
+	}
+	match.Voided = false
+	match.Result = &MatchResult{Outcome: outcome, Score: score, ReportedBy: ctx.User.ID, Status: ResultConfirmed, Time: time.Now()}
+	b.saveMatch(match)
+	b.resultChanged(match)
+	ctx.Reply(fmt.Sprintf("Match **#%d**: %s", match.ID, match.Result))
+}
+
+// Voidmatch makes a match not count, e.g. because it was never played.
+func (b *Bot) Voidmatch(ctx *Context, ref string) {
+	match := b.findMatch(ctx, ref)
+	if match == nil {
+		return
+	}
+	match.Voided = true
+	b.saveMatch(match)
+	b.resultChanged(match)
+	ctx.Reply(fmt.Sprintf("Match **#%d** was voided", match.ID))
+}
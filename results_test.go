@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportResult(t *testing.T) {
+	type step struct {
+		// red or blue for a captain, admin or player for one who isn't.
+		who     string
+		command string
+	}
+	tests := []struct {
+		name    string
+		steps   []step
+		status  ResultStatus
+		outcome Outcome
+		// Expected in the last message, if set.
+		want string
+	}{
+		{"reported", []step{{"red", ".report 1 red 3-1"}}, ResultReported, OutcomeRed, "confirm with `.confirm 1`"},
+		{"confirmed", []step{{"red", ".report 1 red 3-1"}, {"blue", ".confirm 1"}}, ResultConfirmed, OutcomeRed, "Red won 3-1"},
+		{"contested", []step{{"red", ".report 1 red"}, {"blue", ".contest 1"}}, ResultContested, OutcomeRed, "contested"},
+		{"both captains agree", []step{{"red", ".report ctf draw"}, {"blue", ".report #1 draw"}}, ResultConfirmed, OutcomeDraw, "Draw"},
+		{"captains disagree", []step{{"red", ".report 1 red"}, {"blue", ".report 1 blue"}}, ResultContested, OutcomeBlue, "disagree"},
+		{"referee", []step{{"admin", ".report 1 blue"}}, ResultConfirmed, OutcomeBlue, "Blue won"},
+		{"referee settles", []step{{"red", ".report 1 red"}, {"blue", ".contest 1"}, {"admin", ".report 1 blue"}}, ResultConfirmed, OutcomeBlue, "Blue won"},
+		{"not a captain", []step{{"player", ".report 1 red"}}, 0, OutcomeNone, "Only the captains of match #1"},
+		{"own report", []step{{"red", ".report 1 red"}, {"red", ".confirm 1"}}, ResultReported, OutcomeRed, "or a referee can confirm"},
+		{"already confirmed", []step{{"admin", ".report 1 red"}, {"blue", ".report 1 blue"}}, ResultConfirmed, OutcomeRed, "already confirmed"},
+		{"no such match", []step{{"red", ".report 2 red"}}, 0, OutcomeNone, "There is no match #2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tb := newTestBot(t, 4, "countdown 0")
+			tb.playMatch(1, 4)
+			match := tb.matches[0]
+			var player string
+			for _, p := range match.Red {
+				if p.ID != match.RedCaptain {
+					player = p.ID
+				}
+			}
+			for _, step := range test.steps {
+				switch step.who {
+				case "red":
+					tb.run(User{ID: match.RedCaptain}, step.command)
+				case "blue":
+					tb.run(User{ID: match.BlueCaptain}, step.command)
+				case "player":
+					tb.run(User{ID: player}, step.command)
+				default:
+					tb.admin(step.command)
+				}
+			}
+			if test.outcome == OutcomeNone {
+				if match.Result != nil {
+					t.Errorf("result = %s, want none", match.Result)
+				}
+			} else if match.Result == nil || match.Result.Status != test.status || match.Result.Outcome != test.outcome {
+				t.Errorf("result = %v, want %v with status %v", match.Result, test.outcome, test.status)
+			}
+			if last := tb.messenger.Last(testChannel); !strings.Contains(last, test.want) {
+				t.Errorf("last message = %q, want it to contain %q", last, test.want)
+			}
+			wantRated := test.status == ResultConfirmed && test.outcome != OutcomeNone
+			if rated := tb.rating(GameIdentifier{testChannel, "ctf"}, match.RedCaptain).Games > 0; rated != wantRated {
+				t.Errorf("rated %v, want %v", rated, wantRated)
+			}
+		})
+	}
+}
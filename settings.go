@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModSetting is an option of a mod that admins change with .setmod.
+type ModSetting struct {
+	Name        string
+	Description string
+	// Shows the current value.
+	Get func(mod *Mod) string
+	// Parses `value` and stores it on the mod. Returns an error meant for the user.
+	Set func(mod *Mod, value string) error
+	// Called after the setting was changed and saved, if set.
+	Changed func(b *Bot, g GameIdentifier)
+}
+
+var (
+	// All settings in the order they are documented.
+	modSettings []*ModSetting
+	// Names of all settings, used as choices of .setmod.
+	modSettingNames []string
+)
+
+func init() {
+	for _, setting := range defaultModSettings() {
+		modSettings = append(modSettings, setting)
+		modSettingNames = append(modSettingNames, setting.Name)
+	}
+}
+
+func defaultModSettings() []*ModSetting {
+	return []*ModSetting{
+		{
+			Name:        "rating",
+			Description: "How players are rated: elo, glicko2 or trueskill. Changing it rates all matches again",
+			Get: func(mod *Mod) string {
+				return mod.ratingSystemName()
+			},
+			Set: func(mod *Mod, value string) error {
+				for _, name := range ratingSystemNames {
+					if strings.EqualFold(value, name) {
+						mod.RatingSystem = name
+						return nil
+					}
+				}
+				return fmt.Errorf("rating should be %s, got `%s`", strings.Join(ratingSystemNames, ", "), value)
+			},
+			Changed: (*Bot).recomputeRatings,
+		},
+		{
+			Name:        "mingames",
+			Description: "Matches a player needs to be on the leaderboard",
+			Get: func(mod *Mod) string {
+				return strconv.Itoa(mod.minGames())
+			},
+			Set: func(mod *Mod, value string) error {
+				games, err := strconv.Atoi(value)
+				if err != nil || games < 0 {
+					return fmt.Errorf("mingames should be a number of matches, got `%s`", value)
+				}
+				mod.MinGames = &games
+				return nil
+			},
+		},
+		{
+			Name:        "teams",
+			Description: "How teams are formed: draft, picked by captains, or balanced by rating",
+			Get: func(mod *Mod) string {
+				return mod.teamMode()
+			},
+			Set: func(mod *Mod, value string) error {
+				for _, name := range teamModeNames {
+					if strings.EqualFold(value, name) {
+						mod.TeamMode = name
+						return nil
+					}
+				}
+				return fmt.Errorf("teams should be %s, got `%s`", strings.Join(teamModeNames, " or "), value)
+			},
+		},
+		{
+			Name: "captains",
+			Description: "How missing captains are chosen: random, top rated, closest rated, fair (favours who captained least), " +
+				"volunteers only or role first",
+			Get: func(mod *Mod) string {
+				return mod.captainStrategyName()
+			},
+			Set: func(mod *Mod, value string) error {
+				for _, name := range captainStrategyNames {
+					if strings.EqualFold(value, name) {
+						mod.CaptainStrategy = name
+						return nil
+					}
+				}
+				return fmt.Errorf("captains should be %s, got `%s`", strings.Join(captainStrategyNames, ", "), value)
+			},
+		},
+		{
+			Name:        "captainrole",
+			Description: "Role whose players become captains first with the role strategy",
+			Get: func(mod *Mod) string {
+				if mod.CaptainRole == "" {
+					return "none"
+				}
+				return Mentionable{ID: mod.CaptainRole, Role: true}.String()
+			},
+			Set: func(mod *Mod, value string) error {
+				if strings.EqualFold(value, "none") {
+					mod.CaptainRole = ""
+					return nil
+				}
+				match := roleMentionRegexp.FindStringSubmatch(value)
+				if match == nil {
+					return fmt.Errorf("captainrole should be an @role mention or none, got `%s`", value)
+				}
+				mod.CaptainRole = match[1]
+				return nil
+			},
+		},
+		{
+			Name:        "readycheck",
+			Description: "How long players have to confirm they are ready once the mod fills, 0 for no ready check",
+			Get: func(mod *Mod) string {
+				return mod.ReadyCheck.String()
+			},
+			Set: func(mod *Mod, value string) error {
+				readyCheck, err := time.ParseDuration(value)
+				if err != nil || readyCheck < 0 || readyCheck > MaxReadyCheck {
+					return fmt.Errorf("readycheck should be a duration such as 1m, at most %d minutes, got `%s`", int(MaxReadyCheck.Minutes()), value)
+				}
+				mod.ReadyCheck = readyCheck.Round(time.Second)
+				return nil
+			},
+		},
+		{
+			Name:        "countdown",
+			Description: "How long players have to volunteer as captain once the mod fills, 0 to pick captains right away",
+			Get: func(mod *Mod) string {
+				return mod.countdown().String()
+			},
+			Set: func(mod *Mod, value string) error {
+				countdown, err := time.ParseDuration(value)
+				if err != nil || countdown < 0 || countdown > MaxCaptainCountdown {
+					return fmt.Errorf("countdown should be a duration such as 45s, at most %d minutes, got `%s`", int(MaxCaptainCountdown.Minutes()), value)
+				}
+				countdown = countdown.Round(time.Second)
+				mod.Countdown = &countdown
+				return nil
+			},
+		},
+		{
+			Name:        "pickorder",
+			Description: "Turns of the captains: alternate, snake, a sequence like ABBAABBA or turns like 1-2-2-1, red first",
+			Get: func(mod *Mod) string {
+				return mod.pickOrderName()
+			},
+			Set: func(mod *Mod, value string) error {
+				order, err := parsePickOrder(value)
+				if err != nil {
+					return err
+				}
+				mod.PickOrder = order
+				return nil
+			},
+		},
+		{
+			Name:        "picktime",
+			Description: "How long captains have for each turn, 0 for no limit",
+			Get: func(mod *Mod) string {
+				return mod.PickTime.String()
+			},
+			Set: func(mod *Mod, value string) error {
+				pickTime, err := time.ParseDuration(value)
+				if err != nil || pickTime < 0 || pickTime > MaxPickTime {
+					return fmt.Errorf("picktime should be a duration such as 30s, at most %d minutes, got `%s`", int(MaxPickTime.Minutes()), value)
+				}
+				mod.PickTime = pickTime.Round(time.Second)
+				return nil
+			},
+		},
+		{
+			Name: "idlecaptain",
+			Description: "What happens when a captain runs out of time: highest or earliest picks the best rated or first joined " +
+				"players for them, pass makes their first pick captain",
+			Get: func(mod *Mod) string {
+				return mod.idleCaptain()
+			},
+			Set: func(mod *Mod, value string) error {
+				for _, name := range idleCaptainNames {
+					if strings.EqualFold(value, name) {
+						mod.IdleCaptain = name
+						return nil
+					}
+				}
+				return fmt.Errorf("idlecaptain should be %s, got `%s`", strings.Join(idleCaptainNames, ", "), value)
+			},
+		},
+	}
+}
+
+func findModSetting(name string) *ModSetting {
+	for _, setting := range modSettings {
+		if setting.Name == name {
+			return setting
+		}
+	}
+	return nil
+}
+
+// Bot commands
+
+// Setmod changes a setting of a mod. Without a value it shows the setting, and
+// without a setting it shows all of them.
+func (b *Bot) Setmod(ctx *Context, modName string, settingName string, value string) {
+	gameID, mod := b.GameInfo(ctx.ChannelID, modName)
+	if gameID == nil || mod == nil {
+		return
+	}
+	if settingName == "" {
+		lines := []string{fmt.Sprintf("**%s** settings", modName)}
+		for _, setting := range modSettings {
+			lines = append(lines, fmt.Sprintf("`%s` %s :small_orange_diamond: %s", setting.Name, setting.Get(mod), setting.Description))
+		}
+		ctx.ReplyLines(lines)
+		return
+	}
+	setting := findModSetting(settingName)
+	if value == "" {
+		ctx.Reply(fmt.Sprintf("**%s** %s: %s", modName, setting.Name, setting.Get(mod)))
+		return
+	}
+	if err := setting.Set(mod, value); err != nil {
+		ctx.Fail(err.Error())
+		return
+	}
+	if !b.saveChannel(ctx.ChannelID) {
+		return
+	}
+	if setting.Changed != nil {
+		setting.Changed(b, *gameID)
+	}
+	ctx.Reply(fmt.Sprintf("**%s** %s: %s", modName, setting.Name, setting.Get(mod)))
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord shows at most this many autocomplete choices.
+const maxSuggestions = 25
+
+// Registers every command as a slash command, replacing whatever was
+// registered before.
+func registerSlashCommands(s *discordgo.Session) error {
+	var applicationCommands []*discordgo.ApplicationCommand
+	for _, command := range commandList {
+		applicationCommands = append(applicationCommands, command.applicationCommand())
+	}
+	_, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, "", applicationCommands)
+	return err
+}
+
+func (c *Command) applicationCommand() *discordgo.ApplicationCommand {
+	applicationCommand := &discordgo.ApplicationCommand{
+		Name:        c.Name,
+		Description: truncate(c.Description, 100),
+	}
+	for _, arg := range c.Args {
+		option := &discordgo.ApplicationCommandOption{
+			Name:        arg.Name,
+			Description: truncate(arg.Describe(), 100),
+			Type:        discordgo.ApplicationCommandOptionString,
+			Required:    !arg.Optional,
+		}
+		switch {
+		case arg.Variadic:
+			// Slash commands have no repeated options, so these are typed as
+			// one space separated string.
+			option.Description = truncate(arg.Describe()+", separated by spaces", 100)
+			option.Autocomplete = arg.suggests()
+		case arg.Type == ArgInt:
+			option.Type = discordgo.ApplicationCommandOptionInteger
+		case arg.Type == ArgMentionable:
+			option.Type = discordgo.ApplicationCommandOptionMentionable
+		case len(arg.Choices) > 0:
+			for _, choice := range arg.Choices {
+				option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+			}
+		default:
+			option.Autocomplete = arg.suggests()
+		}
+		applicationCommand.Options = append(applicationCommand.Options, option)
+	}
+	return applicationCommand
+}
+
+func (a Arg) suggests() bool {
+	return a.Suggest != nil || a.Type == ArgMod || a.Type == ArgPlayer
+}
+
+func (a Arg) suggestions(b *Bot, ctx *Context) []Suggestion {
+	switch {
+	case a.Suggest != nil:
+		return a.Suggest(b, ctx)
+	case a.Type == ArgMod:
+		return b.suggestMods(ctx)
+	case a.Type == ArgPlayer:
+		return b.suggestPlayers(ctx)
+	}
+	return nil
+}
+
+// Handles slash commands, their autocomplete requests and ready buttons.
+func (b *Bot) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type == discordgo.InteractionMessageComponent && i.MessageComponentData().CustomID == ReadyButtonID {
+		responder := &interactionResponder{session: s, interaction: i.Interaction}
+		b.execute(newInteractionContext(s, i, responder), commandsByName["ready"], nil)
+		return
+	}
+	if i.Type != discordgo.InteractionApplicationCommand && i.Type != discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+	data := i.ApplicationCommandData()
+	command, ok := commandsByName[data.Name]
+	if !ok {
+		return
+	}
+	responder := &interactionResponder{session: s, interaction: i.Interaction}
+	ctx := newInteractionContext(s, i, responder)
+
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		b.autocomplete(s, i, ctx, command, data.Options)
+		return
+	}
+
+	// Options are passed by name: any optional one may be left out.
+	options := make(map[string]string)
+	for _, option := range data.Options {
+		switch option.Type {
+		case discordgo.ApplicationCommandOptionInteger:
+			options[option.Name] = strconv.FormatInt(option.IntValue(), 10)
+		case discordgo.ApplicationCommandOptionMentionable:
+			// Mentionables only carry an ID, the resolved data tells roles from users.
+			target := Mentionable{ID: option.StringValue()}
+			if data.Resolved != nil {
+				_, target.Role = data.Resolved.Roles[target.ID]
+			}
+			options[option.Name] = target.String()
+		default:
+			options[option.Name] = option.StringValue()
+		}
+	}
+	b.executeOptions(ctx, command, options)
+}
+
+func (b *Bot) autocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, ctx *Context, command *Command, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	// Suggesting doesn't queue any messages, so there is nothing to flush and
+	// no reason to wait for other goroutines' messages to be sent.
+	b.mutex.Lock()
+	for _, option := range options {
+		if !option.Focused {
+			continue
+		}
+		for _, arg := range command.Args {
+			if arg.Name != option.Name {
+				continue
+			}
+			// Variadic arguments are completed one word at a time.
+			typed := option.StringValue()
+			var previous string
+			if arg.Variadic {
+				if i := strings.LastIndex(typed, " "); i >= 0 {
+					previous, typed = typed[:i+1], typed[i+1:]
+				}
+			}
+			for _, suggestion := range arg.suggestions(b, ctx) {
+				if len(choices) == maxSuggestions {
+					break
+				}
+				if strings.Contains(strings.ToLower(suggestion.Name), strings.ToLower(typed)) {
+					choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+						Name:  truncate(previous+suggestion.Name, 100),
+						Value: previous + suggestion.Value,
+					})
+				}
+			}
+		}
+	}
+	b.mutex.Unlock()
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		log.Printf("Failed to autocomplete %s: %s", command.Name, err)
+	}
+}
+
+// Builds the context of a slash command.
+func newInteractionContext(s *discordgo.Session, i *discordgo.InteractionCreate, responder responder) *Context {
+	ctx := &Context{ChannelID: i.ChannelID, GuildID: i.GuildID, responder: responder}
+	if i.Member != nil {
+		ctx.User = User{i.Member.User.ID, i.Member.User.Username, i.Member.Nick}
+		ctx.Roles = i.Member.Roles
+		ctx.GuildAdmin = i.Member.Permissions&guildAdminPermissions != 0
+	} else if i.User != nil {
+		ctx.User = User{ID: i.User.ID, Username: i.User.Username}
+	}
+	if i.Type == discordgo.InteractionApplicationCommand {
+		if resolved := i.ApplicationCommandData().Resolved; resolved != nil {
+			for _, user := range resolved.Users {
+				ctx.Mentions = append(ctx.Mentions, guildUser(s, i.GuildID, user))
+			}
+		}
+	}
+	return ctx
+}
+
+// interactionResponder collects the answers to a slash command and sends them
+// once the command has finished, before the messages it queued. Errors are only shown to the user who ran it.
+type interactionResponder struct {
+	session     *discordgo.Session
+	interaction *discordgo.Interaction
+	replies     []string
+	failures    []string
+}
+
+func (r *interactionResponder) reply(content string) {
+	r.replies = append(r.replies, content)
+}
+
+func (r *interactionResponder) fail(content string) {
+	r.failures = append(r.failures, content)
+}
+
+func (r *interactionResponder) deny(reason string) {
+	r.fail(reason)
+}
+
+func (r *interactionResponder) ack() {}
+
+func (r *interactionResponder) flush() {
+	var err error
+	switch {
+	case len(r.replies) > 0:
+		err = r.respond(r.replies[0], 0)
+		for _, reply := range r.replies[1:] {
+			r.followup(reply, 0)
+		}
+		for _, failure := range r.failures {
+			r.followup(failure, discordgo.MessageFlagsEphemeral)
+		}
+	case len(r.failures) > 0:
+		err = r.respond(strings.Join(r.failures, "\n"), discordgo.MessageFlagsEphemeral)
+	default:
+		err = r.respond("✅", discordgo.MessageFlagsEphemeral)
+	}
+	if err != nil {
+		log.Printf("Failed to respond to interaction: %s", err)
+	}
+}
+
+func (r *interactionResponder) respond(content string, flags discordgo.MessageFlags) error {
+	return r.session.InteractionRespond(r.interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: content, Flags: flags, AllowedMentions: allowedMentions},
+	})
+}
+
+func (r *interactionResponder) followup(content string, flags discordgo.MessageFlags) {
+	_, err := r.session.FollowupMessageCreate(r.interaction, false, &discordgo.WebhookParams{Content: content, Flags: flags, AllowedMentions: allowedMentions})
+	if err != nil {
+		log.Printf("Failed to send followup: %s", err)
+	}
+}
+
+// Autocomplete sources
+
+func (b *Bot) suggestMods(ctx *Context) []Suggestion {
+	var suggestions []Suggestion
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		for name := range c.Mods {
+			suggestions = append(suggestions, Suggestion{name, name})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Name < suggestions[j].Name
+	})
+	return suggestions
+}
+
+// Suggests players who joined a mod on the channel and haven't been picked yet.
+func (b *Bot) suggestPlayers(ctx *Context) []Suggestion {
+	var suggestions []Suggestion
+	seen := make(map[string]bool)
+	for g, game := range b.games {
+		if g.Channel != ctx.ChannelID {
+			continue
+		}
+		for _, player := range game.PlayersSortedByJoinTime() {
+			if !seen[player.Key] {
+				seen[player.Key] = true
+				suggestions = append(suggestions, Suggestion{player.Value.Name, mention(player.Key)})
+			}
+		}
+	}
+	return suggestions
+}
+
+// Suggests the picking numbers of players that are left to pick.
+func (b *Bot) suggestPickingNumbers(ctx *Context) []Suggestion {
+	var suggestions []Suggestion
+	if c, ok := b.channels[ctx.ChannelID]; ok {
+		for name, mod := range c.Mods {
+			game, ok := b.games[GameIdentifier{ctx.ChannelID, name}]
+			if !ok || !game.IsPickingTeams(mod) {
+				continue
+			}
+			for _, player := range game.PlayersSortedByJoinTime() {
+				number := strconv.Itoa(player.Value.PickingNumber)
+				suggestions = append(suggestions, Suggestion{fmt.Sprintf("%s) %s", number, player.Value.Name), number})
+			}
+		}
+	}
+	return suggestions
+}
+
+// Suggests the latest matches of the channel.
+func (b *Bot) suggestMatches(ctx *Context) []Suggestion {
+	var suggestions []Suggestion
+	for _, match := range b.lastMatches(ctx.ChannelID, "", 0, maxSuggestions) {
+		id := strconv.Itoa(match.ID)
+		suggestions = append(suggestions, Suggestion{fmt.Sprintf("#%s %s, %s", id, match.Mod, formatAge(time.Since(match.PickedTime))), id})
+	}
+	return suggestions
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length-1] + "…"
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rated matches the rating trend of .stats looks back on.
+const TrendMatches = 5
+
+// Number of teammates .stats lists.
+const FavouriteTeammates = 3
+
+// Returns the scores of a player after each of their rated matches in a mod,
+// oldest first.
+func (b *Bot) scoreHistory(g GameIdentifier, userID string) []float64 {
+	system := b.channels[g.Channel].Mods[g.Mod].ratingSystem()
+	var scores []float64
+	b.rateMatches(g, func(match *Match, ratings map[string]*Rating) {
+		if match.hasPlayer(userID) {
+			scores = append(scores, system.Score(ratings[userID]))
+		}
+	})
+	return scores
+}
+
+func (match *Match) hasPlayer(userID string) bool {
+	for _, team := range [][]MatchPlayer{match.Red, match.Blue} {
+		for _, player := range team {
+			if player.ID == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Finds the record of a player in `records`, by name if `user` wasn't mentioned.
+func findRecord(records map[string]*PlayerRecord, user User) *PlayerRecord {
+	if user.ID != "" {
+		return records[user.ID]
+	}
+	for _, record := range records {
+		if strings.EqualFold(record.Name, user.DisplayName()) {
+			return record
+		}
+	}
+	return nil
+}
+
+// Describes the stats of a player in a mod.
+func (b *Bot) playerStats(g GameIdentifier, records map[string]*PlayerRecord, record *PlayerRecord) string {
+	mod := b.channels[g.Channel].Mods[g.Mod]
+	system := mod.ratingSystem()
+	var lines []string
+	lines = append(lines, fmt.Sprintf("**%s** in **%s**", record.Name, g.Mod))
+	lines = append(lines, fmt.Sprintf("Games: %d :small_orange_diamond: %d as captain :small_orange_diamond: %d-%d-%d (%.0f%% won)",
+		record.Games, record.Captained, record.Wins, record.Losses, record.Draws, 100*record.WinRate()))
+
+	rating := fmt.Sprintf("Rating: %.0f (%s)", system.Score(b.rating(g, record.ID)), mod.ratingSystemName())
+	if scores := b.scoreHistory(g, record.ID); len(scores) > 0 {
+		current := scores[len(scores)-1]
+		initial := system.New()
+		previous := system.Score(&initial)
+		matches := len(scores)
+		if matches > TrendMatches {
+			previous = scores[len(scores)-1-TrendMatches]
+			matches = TrendMatches
+		}
+		rating += fmt.Sprintf(" :small_orange_diamond: %+.0f over the last %d rated matches", current-previous, matches)
+	}
+	lines = append(lines, rating)
+
+	if record.Picked > 0 {
+		lines = append(lines, fmt.Sprintf("Average pick: %.1f", record.AveragePick()))
+	}
+	if teammates := favouriteTeammates(records, record); teammates != "" {
+		lines = append(lines, "Favourite teammates: "+teammates)
+	}
+	lines = append(lines, "Last played "+formatAge(time.Since(record.LastPlayed)))
+	return strings.Join(lines, "\n")
+}
+
+// Lists the players `record` played with most, with the number of matches.
+func favouriteTeammates(records map[string]*PlayerRecord, record *PlayerRecord) string {
+	var ids []string
+	for id := range record.Teammates {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if record.Teammates[ids[i]] != record.Teammates[ids[j]] {
+			return record.Teammates[ids[i]] > record.Teammates[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	var teammates []string
+	for i := 0; i < len(ids) && i < FavouriteTeammates; i++ {
+		teammates = append(teammates, fmt.Sprintf("%s (%d)", records[ids[i]].Name, record.Teammates[ids[i]]))
+	}
+	return strings.Join(teammates, ", ")
+}
+
+// Bot commands
+
+// Stats shows how a player did in one or all mods of the channel.
+func (b *Bot) Stats(ctx *Context, user User, modName string) {
+	c, ok := b.channels[ctx.ChannelID]
+	if !ok {
+		return
+	}
+	var modNames []string
+	for name := range c.Mods {
+		if modName == "" || name == modName {
+			modNames = append(modNames, name)
+		}
+	}
+	sort.Strings(modNames)
+
+	var stats []string
+	for _, name := range modNames {
+		g := GameIdentifier{ctx.ChannelID, name}
+		records := playerRecords(b.playedMatches(g))
+		if record := findRecord(records, user); record != nil {
+			if len(stats) > 0 {
+				stats = append(stats, "")
+			}
+			stats = append(stats, b.playerStats(g, records, record))
+		}
+	}
+	if len(stats) == 0 {
+		name := user.DisplayName()
+		if name == "" {
+			name = mention(user.ID)
+		}
+		ctx.Reply(fmt.Sprintf("%s didn't play here yet", name))
+		return
+	}
+	ctx.ReplyLines(stats)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatsKeepHistoryAfterRatingsReset(t *testing.T) {
+	tb, match := playReportedMatch(t)
+	tb.run(User{ID: match.BlueCaptain}, ".confirm 1")
+	tb.admin(".setmod ctf mingames 0")
+	captain := match.RedCaptain
+	teammate := match.Red[1].Name
+
+	stats := func() string {
+		tb.messenger.Reset()
+		tb.run(testUser(1), ".stats <@"+captain+"> ctf")
+		return strings.Join(tb.messenger.Sent(testChannel), "\n")
+	}
+	for _, want := range []string{"Games: 1 :small_orange_diamond: 1 as captain :small_orange_diamond: 1-0-0 (100% won)", "Favourite teammates: " + teammate + " (1)", "Last played just now"} {
+		if sent := stats(); !strings.Contains(sent, want) {
+			t.Errorf("stats = %q, want %q", sent, want)
+		}
+	}
+
+	// Resetting ratings only starts the ratings and the leaderboard over.
+	tb.admin(".resetratings ctf")
+	sent := stats()
+	for _, want := range []string{"Games: 1 :small_orange_diamond: 1 as captain", "Rating: 1500 (elo)", "Favourite teammates: " + teammate} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("stats after reset = %q, want %q", sent, want)
+		}
+	}
+	tb.run(testUser(1), ".lb ctf")
+	tb.expectSent("No **ctf** match was played yet")
+
+	tb.run(testUser(9), ".stats")
+	tb.expectSent("Player9 didn't play here yet")
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	StorageFirestore = "firestore"
+	StorageBolt      = "bolt"
+	StorageLevelDB   = "leveldb"
+	StorageMemory    = "memory"
+)
+
+const (
+	channelsCollection = "channels"
+	gamesCollection    = "games"
+	matchesCollection  = "matches"
+	ratingsCollection  = "ratings"
+)
+
+// Storage persists bot state. Bot only talks to this interface so the same
+// binary can run against Firestore or a local database file.
+type Storage interface {
+	Channels() (map[string]*Channel, error)
+	SaveChannel(channelID string, channel *Channel) error
+	DeleteChannel(channelID string) error
+	Games() (map[GameIdentifier]*Game, error)
+	SaveGame(id GameIdentifier, game *Game) error
+	DeleteGame(id GameIdentifier) error
+	// Matches returns all finished matches ordered by ID.
+	Matches() ([]*Match, error)
+	SaveMatch(match *Match) error
+	// Ratings returns the ratings of each mod by user ID.
+	Ratings() (map[GameIdentifier]map[string]*Rating, error)
+	SaveRatings(id GameIdentifier, ratings map[string]*Rating) error
+	Close() error
+}
+
+// storedGame is how a game is written to storage. The identifier is kept next
+// to the game so that keys never have to be parsed back.
+type storedGame struct {
+	Channel string
+	Mod     string
+	Game    *Game
+}
+
+// storedRatings holds the ratings of all players of a mod.
+type storedRatings struct {
+	Channel string
+	Mod     string
+	Ratings map[string]*Rating
+}
+
+// Key used to store a game. Firestore document IDs can't contain slashes, so
+// stick to a colon.
+func (g GameIdentifier) key() string {
+	return g.Channel + ":" + g.Mod
+}
+
+// Key used to store a match.
+func (m *Match) key() string {
+	return strconv.Itoa(m.ID)
+}
+
+func sortMatches(matches []*Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ID < matches[j].ID
+	})
+}
+
+// NewStorage opens the storage backend called `kind`. `project` is only used by
+// Firestore and `path` only by the local backends.
+func NewStorage(ctx context.Context, kind string, project string, path string) (Storage, error) {
+	switch kind {
+	case StorageFirestore:
+		return newFirestoreStorage(ctx, project)
+	case StorageBolt:
+		return newBoltStorage(path)
+	case StorageLevelDB:
+		return newLevelDBStorage(path)
+	case StorageMemory:
+		return NewMemoryStorage(), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", kind)
+}
+
+// kvBackend is a minimal key/value store grouped in collections. Local
+// databases implement it and kvStorage takes care of encoding.
+type kvBackend interface {
+	put(collection string, key string, value []byte) error
+	delete(collection string, key string) error
+	each(collection string, fn func(key string, value []byte) error) error
+	close() error
+}
+
+// kvStorage implements Storage on top of any kvBackend by storing values as JSON.
+type kvStorage struct {
+	db kvBackend
+}
+
+func (kv *kvStorage) Channels() (map[string]*Channel, error) {
+	channels := make(map[string]*Channel)
+	err := kv.db.each(channelsCollection, func(key string, value []byte) error {
+		var c Channel
+		if err := json.Unmarshal(value, &c); err != nil {
+			return err
+		}
+		channels[key] = &c
+		return nil
+	})
+	return channels, err
+}
+
+func (kv *kvStorage) SaveChannel(channelID string, channel *Channel) error {
+	return kv.putJSON(channelsCollection, channelID, channel)
+}
+
+func (kv *kvStorage) DeleteChannel(channelID string) error {
+	return kv.db.delete(channelsCollection, channelID)
+}
+
+func (kv *kvStorage) Games() (map[GameIdentifier]*Game, error) {
+	games := make(map[GameIdentifier]*Game)
+	err := kv.db.each(gamesCollection, func(key string, value []byte) error {
+		var g storedGame
+		if err := json.Unmarshal(value, &g); err != nil {
+			return err
+		}
+		games[GameIdentifier{g.Channel, g.Mod}] = g.Game
+		return nil
+	})
+	return games, err
+}
+
+func (kv *kvStorage) SaveGame(id GameIdentifier, game *Game) error {
+	return kv.putJSON(gamesCollection, id.key(), storedGame{id.Channel, id.Mod, game})
+}
+
+func (kv *kvStorage) DeleteGame(id GameIdentifier) error {
+	return kv.db.delete(gamesCollection, id.key())
+}
+
+func (kv *kvStorage) Matches() ([]*Match, error) {
+	var matches []*Match
+	err := kv.db.each(matchesCollection, func(key string, value []byte) error {
+		var m Match
+		if err := json.Unmarshal(value, &m); err != nil {
+			return err
+		}
+		matches = append(matches, &m)
+		return nil
+	})
+	sortMatches(matches)
+	return matches, err
+}
+
+func (kv *kvStorage) SaveMatch(match *Match) error {
+	return kv.putJSON(matchesCollection, match.key(), match)
+}
+
+func (kv *kvStorage) Ratings() (map[GameIdentifier]map[string]*Rating, error) {
+	ratings := make(map[GameIdentifier]map[string]*Rating)
+	err := kv.db.each(ratingsCollection, func(key string, value []byte) error {
+		var r storedRatings
+		if err := json.Unmarshal(value, &r); err != nil {
+			return err
+		}
+		ratings[GameIdentifier{r.Channel, r.Mod}] = r.Ratings
+		return nil
+	})
+	return ratings, err
+}
+
+func (kv *kvStorage) SaveRatings(id GameIdentifier, ratings map[string]*Rating) error {
+	return kv.putJSON(ratingsCollection, id.key(), storedRatings{id.Channel, id.Mod, ratings})
+}
+
+func (kv *kvStorage) Close() error {
+	return kv.db.close()
+}
+
+func (kv *kvStorage) putJSON(collection string, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return kv.db.put(collection, key, data)
+}
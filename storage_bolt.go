@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBackend stores each collection in its own bucket.
+type boltBackend struct {
+	db *bolt.DB
+}
+
+func newBoltStorage(path string) (*kvStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &kvStorage{&boltBackend{db}}, nil
+}
+
+func (b *boltBackend) put(collection string, key string, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(collection))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+func (b *boltBackend) delete(collection string, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(key))
+	})
+}
+
+func (b *boltBackend) each(collection string, fn func(key string, value []byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+func (b *boltBackend) close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+type firestoreStorage struct {
+	client *firestore.Client
+	ctx    context.Context
+}
+
+func newFirestoreStorage(ctx context.Context, project string) (*firestoreStorage, error) {
+	client, err := firestore.NewClient(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return &firestoreStorage{client, ctx}, nil
+}
+
+func (f *firestoreStorage) Channels() (map[string]*Channel, error) {
+	channels := make(map[string]*Channel)
+	iter := f.client.Collection(channelsCollection).Documents(f.ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var c Channel
+		if err := doc.DataTo(&c); err != nil {
+			return nil, err
+		}
+		channels[doc.Ref.ID] = &c
+	}
+	return channels, nil
+}
+
+func (f *firestoreStorage) SaveChannel(channelID string, channel *Channel) error {
+	_, err := f.client.Collection(channelsCollection).Doc(channelID).Set(f.ctx, channel)
+	return err
+}
+
+func (f *firestoreStorage) DeleteChannel(channelID string) error {
+	_, err := f.client.Collection(channelsCollection).Doc(channelID).Delete(f.ctx)
+	return err
+}
+
+func (f *firestoreStorage) Games() (map[GameIdentifier]*Game, error) {
+	games := make(map[GameIdentifier]*Game)
+	iter := f.client.Collection(gamesCollection).Documents(f.ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var g storedGame
+		if err := doc.DataTo(&g); err != nil {
+			return nil, err
+		}
+		games[GameIdentifier{g.Channel, g.Mod}] = g.Game
+	}
+	return games, nil
+}
+
+func (f *firestoreStorage) SaveGame(id GameIdentifier, game *Game) error {
+	_, err := f.client.Collection(gamesCollection).Doc(id.key()).Set(f.ctx, storedGame{id.Channel, id.Mod, game})
+	return err
+}
+
+func (f *firestoreStorage) DeleteGame(id GameIdentifier) error {
+	_, err := f.client.Collection(gamesCollection).Doc(id.key()).Delete(f.ctx)
+	return err
+}
+
+func (f *firestoreStorage) Matches() ([]*Match, error) {
+	var matches []*Match
+	iter := f.client.Collection(matchesCollection).Documents(f.ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var m Match
+		if err := doc.DataTo(&m); err != nil {
+			return nil, err
+		}
+		matches = append(matches, &m)
+	}
+	sortMatches(matches)
+	return matches, nil
+}
+
+func (f *firestoreStorage) SaveMatch(match *Match) error {
+	_, err := f.client.Collection(matchesCollection).Doc(match.key()).Set(f.ctx, match)
+	return err
+}
+
+func (f *firestoreStorage) Ratings() (map[GameIdentifier]map[string]*Rating, error) {
+	ratings := make(map[GameIdentifier]map[string]*Rating)
+	iter := f.client.Collection(ratingsCollection).Documents(f.ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var r storedRatings
+		if err := doc.DataTo(&r); err != nil {
+			return nil, err
+		}
+		ratings[GameIdentifier{r.Channel, r.Mod}] = r.Ratings
+	}
+	return ratings, nil
+}
+
+func (f *firestoreStorage) SaveRatings(id GameIdentifier, ratings map[string]*Rating) error {
+	_, err := f.client.Collection(ratingsCollection).Doc(id.key()).Set(f.ctx, storedRatings{id.Channel, id.Mod, ratings})
+	return err
+}
+
+func (f *firestoreStorage) Close() error {
+	return f.client.Close()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// levelDBBackend has no notion of buckets, so keys are prefixed with the
+// collection name, e.g. channels/1234.
+type levelDBBackend struct {
+	db *leveldb.DB
+}
+
+func newLevelDBStorage(path string) (*kvStorage, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &kvStorage{&levelDBBackend{db}}, nil
+}
+
+func levelDBKey(collection string, key string) []byte {
+	return []byte(collection + "/" + key)
+}
+
+func (l *levelDBBackend) put(collection string, key string, value []byte) error {
+	return l.db.Put(levelDBKey(collection, key), value, nil)
+}
+
+func (l *levelDBBackend) delete(collection string, key string) error {
+	return l.db.Delete(levelDBKey(collection, key), nil)
+}
+
+func (l *levelDBBackend) each(collection string, fn func(key string, value []byte) error) error {
+	prefix := collection + "/"
+	iter := l.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		// The iterator reuses its buffers, so copy the value before handing it out.
+		value := append([]byte(nil), iter.Value()...)
+		if err := fn(strings.TrimPrefix(string(iter.Key()), prefix), value); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+func (l *levelDBBackend) close() error {
+	return l.db.Close()
+}
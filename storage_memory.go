@@ -0,0 +1,50 @@
+package main
+
+import "sync"
+
+// memoryBackend keeps everything in memory. Nothing survives a restart, which
+// makes it useful for trying the bot out and for tests.
+type memoryBackend struct {
+	mutex       sync.Mutex
+	collections map[string]map[string][]byte
+}
+
+func NewMemoryStorage() Storage {
+	return &kvStorage{&memoryBackend{collections: make(map[string]map[string][]byte)}}
+}
+
+func (mem *memoryBackend) put(collection string, key string, value []byte) error {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	if _, ok := mem.collections[collection]; !ok {
+		mem.collections[collection] = make(map[string][]byte)
+	}
+	mem.collections[collection][key] = value
+	return nil
+}
+
+func (mem *memoryBackend) delete(collection string, key string) error {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	delete(mem.collections[collection], key)
+	return nil
+}
+
+func (mem *memoryBackend) each(collection string, fn func(key string, value []byte) error) error {
+	mem.mutex.Lock()
+	values := make(map[string][]byte)
+	for key, value := range mem.collections[collection] {
+		values[key] = value
+	}
+	mem.mutex.Unlock()
+	for key, value := range values {
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mem *memoryBackend) close() error {
+	return nil
+}
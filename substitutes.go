@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (match *Match) team(color TeamColor) []MatchPlayer {
+	if color == Blue {
+		return match.Blue
+	}
+	return match.Red
+}
+
+func (match *Match) captain(color TeamColor) string {
+	if color == Blue {
+		return match.BlueCaptain
+	}
+	return match.RedCaptain
+}
+
+// Finds a player of the match by user ID or, failing that, by name. Returns
+// their team and their index in it, or false if they didn't play.
+func (match *Match) findPlayer(user User) (TeamColor, int, bool) {
+	for _, byName := range []bool{false, true} {
+		for _, color := range []TeamColor{Red, Blue} {
+			for i, player := range match.team(color) {
+				if (!byName && player.ID == user.ID) || (byName && user.ID == "" && strings.EqualFold(player.Name, user.DisplayName())) {
+					return color, i, true
+				}
+			}
+		}
+	}
+	return Red, 0, false
+}
+
+// Stores a match whose players changed and rates its mod again if it counts.
+func (b *Bot) lineupChanged(ctx *Context, match *Match) {
+	b.saveMatch(match)
+	if match.Outcome() != OutcomeNone {
+		b.resultChanged(match)
+	}
+	ctx.Reply(match.Summary())
+}
+
+// Bot commands
+
+// Sub replaces a player of a match by someone who didn't play in it. Captains
+// can substitute players of their own team until the result is confirmed,
+// referees can substitute anyone.
+func (b *Bot) Sub(ctx *Context, ref string, out User, in User) {
+	match := b.findMatch(ctx, ref)
+	if match == nil {
+		return
+	}
+	color, i, ok := match.findPlayer(out)
+	if !ok {
+		ctx.Fail(fmt.Sprintf("%s didn't play in match #%d", out.DisplayName(), match.ID))
+		return
+	}
+	player := &match.team(color)[i]
+	if in.ID == "" {
+		ctx.Fail(fmt.Sprintf("Mention the player coming in, e.g. `%ssub %d %s @%s`", CommandPrefix, match.ID, player.Name, in.Username))
+		return
+	}
+	if _, _, ok := match.findPlayer(User{ID: in.ID}); ok {
+		ctx.Fail(fmt.Sprintf("%s already played in match #%d, use `%sswap` to move them", mention(in.ID), match.ID, CommandPrefix))
+		return
+	}
+	confirmed := match.Result != nil && match.Result.Status == ResultConfirmed
+	if !b.hasPermission(ctx, PermissionReferee) && (match.captain(color) != ctx.User.ID || confirmed) {
+		ctx.Fail(fmt.Sprintf("Only referees and, until the result is confirmed, the captain of %s's team can substitute them", player.Name))
+		return
+	}
+
+	if match.captain(color) == player.ID {
+		if color == Red {
+			match.RedCaptain = in.ID
+		} else {
+			match.BlueCaptain = in.ID
+		}
+		// The other captain confirms the new captain's report, not the old one's.
+		if match.Result != nil && match.Result.ReportedBy == player.ID {
+			match.Result.ReportedBy = in.ID
+		}
+	}
+	// Substitutes weren't picked, so they don't count towards pick positions.
+	*player = MatchPlayer{ID: in.ID, Name: in.DisplayName(), JoinTime: time.Now(), Substitute: true}
+	if player.Name == "" {
+		player.Name = mention(in.ID)
+	}
+	b.lineupChanged(ctx, match)
+}
+
+// Swap moves two players of a match to each other's team.
+func (b *Bot) Swap(ctx *Context, ref string, first User, second User) {
+	match := b.findMatch(ctx, ref)
+	if match == nil {
+		return
+	}
+	var players [2]*MatchPlayer
+	var colors [2]TeamColor
+	for x, user := range []User{first, second} {
+		color, i, ok := match.findPlayer(user)
+		if !ok {
+			ctx.Fail(fmt.Sprintf("%s didn't play in match #%d", user.DisplayName(), match.ID))
+			return
+		}
+		players[x], colors[x] = &match.team(color)[i], color
+	}
+	if colors[0] == colors[1] {
+		ctx.Fail(fmt.Sprintf("%s and %s are on the same team", players[0].Name, players[1].Name))
+		return
+	}
+	if match.isCaptain(players[0].ID) || match.isCaptain(players[1].ID) {
+		ctx.Fail("Captains can't change teams, substitute them instead")
+		return
+	}
+	*players[0], *players[1] = *players[1], *players[0]
+	b.lineupChanged(ctx, match)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+// Plays a 4 player match and lets its red captain report a red win.
+func playReportedMatch(t *testing.T) (*testBot, *Match) {
+	tb := newTestBot(t, 4, "countdown 0")
+	tb.join(1, 4)
+	for tb.game().IsPickingTeams(tb.mod()) {
+		tb.pickTurn()
+	}
+	match := tb.matches[0]
+	tb.run(User{ID: match.RedCaptain}, ".report 1 red")
+	return tb, match
+}
+
+func TestSubCaptainKeepsReport(t *testing.T) {
+	tb, match := playReportedMatch(t)
+	blueCaptain := match.BlueCaptain
+	tb.admin(".sub 1 <@" + match.RedCaptain + "> <@" + testUser(9).ID + ">")
+	if match.RedCaptain != testUser(9).ID || match.Result.ReportedBy != testUser(9).ID {
+		t.Fatalf("red captain %s reported by %s, want both %s", match.RedCaptain, match.Result.ReportedBy, testUser(9).ID)
+	}
+	tb.run(User{ID: blueCaptain}, ".confirm 1")
+	if match.Result.Status != ResultConfirmed {
+		t.Errorf("blue captain couldn't confirm: %s", tb.messenger.Last(testChannel))
+	}
+}
+
+func TestSubIsNoPick(t *testing.T) {
+	tb, match := playReportedMatch(t)
+	if picked := match.team(Red)[1]; picked.PickedOrder == 0 {
+		t.Fatalf("%s should have been picked", picked.Name)
+	}
+	tb.admin(".sub 1 <@" + match.team(Red)[1].ID + "> <@" + testUser(9).ID + ">")
+	sub := match.team(Red)[1]
+	if sub.ID != testUser(9).ID || !sub.Substitute || sub.PickedOrder != 0 {
+		t.Errorf("substitute = %+v, want an unpicked substitute", sub)
+	}
+	if record := playerRecords(tb.playedMatches(GameIdentifier{testChannel, "ctf"}))[testUser(9).ID]; record.Picked != 0 {
+		t.Errorf("substitute counted as picked %d times", record.Picked)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// How the teams of a mod are formed once it fills.
+const (
+	// Captains take turns picking players.
+	TeamModeDraft = "draft"
+	// The bot splits the players into teams of equal strength.
+	TeamModeBalanced = "balanced"
+)
+
+var teamModeNames = []string{TeamModeDraft, TeamModeBalanced}
+
+// Most players the teams are balanced for by trying every split. Larger games
+// are split greedily.
+const MaxExhaustiveBalance = 16
+
+func (mod *Mod) teamMode() string {
+	if mod.TeamMode == "" {
+		return TeamModeDraft
+	}
+	return mod.TeamMode
+}
+
+// Splits `players` into red and blue so that the predicted win probability is
+// as close to even as possible. Red gets the smaller half if the number of
+// players is odd. Players are shuffled first so that equally balanced splits,
+// e.g. of players who are all unrated, don't always come out the same.
+func balanceTeams(system RatingSystem, players []string, rating func(id string) *Rating) ([]string, []string) {
+	players = append([]string(nil), players...)
+	rand.Shuffle(len(players), func(i, j int) {
+		players[i], players[j] = players[j], players[i]
+	})
+	ratings := make([]*Rating, len(players))
+	for i, id := range players {
+		ratings[i] = rating(id)
+	}
+	redSize := len(players) / 2
+
+	if len(players) > MaxExhaustiveBalance {
+		// Give the best remaining player to the weaker team while it has room.
+		order := make([]int, len(players))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return system.Score(ratings[order[i]]) > system.Score(ratings[order[j]])
+		})
+		var red, blue []string
+		var redTotal, blueTotal float64
+		for _, i := range order {
+			score := system.Score(ratings[i])
+			if len(blue) == len(players)-redSize || (len(red) < redSize && redTotal <= blueTotal) {
+				red = append(red, players[i])
+				redTotal += score
+			} else {
+				blue = append(blue, players[i])
+				blueTotal += score
+			}
+		}
+		return red, blue
+	}
+
+	bestMask, bestDifference := uint(0), math.Inf(1)
+	for mask := uint(0); mask < 1<<uint(len(players)); mask++ {
+		if bits.OnesCount(mask) != redSize {
+			continue
+		}
+		var red, blue []*Rating
+		for i, r := range ratings {
+			if mask&(1<<uint(i)) != 0 {
+				red = append(red, r)
+			} else {
+				blue = append(blue, r)
+			}
+		}
+		if difference := math.Abs(system.WinProbability(red, blue) - 0.5); difference < bestDifference {
+			bestMask, bestDifference = mask, difference
+		}
+	}
+	var red, blue []string
+	for i, id := range players {
+		if bestMask&(1<<uint(i)) != 0 {
+			red = append(red, id)
+		} else {
+			blue = append(blue, id)
+		}
+	}
+	return red, blue
+}
+
+// Forms the teams of a full game of a balanced mod and finishes it. The best
+// rated player of each team becomes its captain, so that there is someone to
+// report the result.
+func (b *Bot) balanceGame(g GameIdentifier, mod *Mod) {
+	game := b.games[g]
+	system := mod.ratingSystem()
+	var players []string
+	for id := range game.Players {
+		players = append(players, id)
+	}
+	rating := func(id string) *Rating {
+		return b.rating(g, id)
+	}
+	red, blue := balanceTeams(system, players, rating)
+
+	var message []string
+	for _, team := range []struct {
+		players []string
+		members map[string]*PlayerMetadata
+	}{{red, game.Red}, {blue, game.Blue}} {
+		sort.SliceStable(team.players, func(i, j int) bool {
+			return system.Score(rating(team.players[i])) > system.Score(rating(team.players[j]))
+		})
+		for i, id := range team.players {
+			if i == 0 {
+				// Red's captain is set first, so this makes each player captain of their own team.
+				message = append(message, game.SetNextCaptainIfPossible(id, game.Players[id]))
+				continue
+			}
+			team.members[id] = game.Players[id]
+			delete(game.Players, id)
+		}
+	}
+	message = append(message, b.selectTeams(g))
+	b.messenger.Send(g.Channel, strings.Join(message, "\n"))
+	b.refill(g)
+}
+
+// Describes the average rating of both teams of a game and who is favoured.
+func (b *Bot) teamPrediction(g GameIdentifier, game *Game) string {
+	system := b.channels[g.Channel].Mods[g.Mod].ratingSystem()
+	team := func(members map[string]*PlayerMetadata) []*Rating {
+		var ratings []*Rating
+		for id := range members {
+			ratings = append(ratings, b.rating(g, id))
+		}
+		return ratings
+	}
+	red, blue := team(game.Red), team(game.Blue)
+	redChance := system.WinProbability(red, blue)
+	return fmt.Sprintf("Average rating: Red %.0f :small_orange_diamond: Blue %.0f\nChance of winning: Red %.0f%% :small_orange_diamond: Blue %.0f%%",
+		meanRating(red, system.Score), meanRating(blue, system.Score),
+		100*redChance, 100*(1-redChance))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBalanceTeams(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratings []float64
+		// Ratings of the red team, sorted, if only one split is balanced.
+		red []float64
+	}{
+		{"pairs", []float64{1000, 1200, 1800, 2000}, []float64{1000, 2000}},
+		{"odd", []float64{1000, 1500, 2000}, []float64{1500}},
+		{"duel", []float64{1400, 1600}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ratings := make(map[string]*Rating)
+			var players []string
+			for i, value := range test.ratings {
+				id := fmt.Sprint(i)
+				ratings[id] = &Rating{Value: value}
+				players = append(players, id)
+			}
+			red, blue := balanceTeams(elo{}, players, func(id string) *Rating { return ratings[id] })
+			if len(red) != len(players)/2 || len(red)+len(blue) != len(players) {
+				t.Fatalf("teams of %d and %d from %d players", len(red), len(blue), len(players))
+			}
+			if test.red == nil {
+				return
+			}
+			var got []float64
+			for _, id := range red {
+				got = append(got, ratings[id].Value)
+			}
+			sort.Float64s(got)
+			// Either team may get the balanced half when sizes are equal.
+			if fmt.Sprint(got) != fmt.Sprint(test.red) && len(red) == len(blue) {
+				got = nil
+				for _, id := range blue {
+					got = append(got, ratings[id].Value)
+				}
+				sort.Float64s(got)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.red) {
+				t.Errorf("red = %v, want %v", got, test.red)
+			}
+		})
+	}
+}
+
+func TestBalanceTeamsVariesTies(t *testing.T) {
+	var players []string
+	for i := 0; i < 8; i++ {
+		players = append(players, fmt.Sprint(i))
+	}
+	unrated := func(id string) *Rating {
+		rating := elo{}.New()
+		return &rating
+	}
+	splits := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		red, _ := balanceTeams(elo{}, players, unrated)
+		sort.Strings(red)
+		splits[strings.Join(red, " ")] = true
+	}
+	if len(splits) < 2 {
+		t.Errorf("unrated players were split the same way 20 times: %v", splits)
+	}
+}
+
+func TestBalanceTeamsGreedy(t *testing.T) {
+	ratings := make(map[string]*Rating)
+	var players []string
+	for i := 0; i < MaxExhaustiveBalance+2; i++ {
+		id := fmt.Sprint(i)
+		ratings[id] = &Rating{Value: 1000 + 50*float64(i)}
+		players = append(players, id)
+	}
+	rating := func(id string) *Rating { return ratings[id] }
+	red, blue := balanceTeams(elo{}, players, rating)
+	if len(red) != len(blue) {
+		t.Fatalf("teams of %d and %d", len(red), len(blue))
+	}
+	team := func(ids []string) []*Rating {
+		var team []*Rating
+		for _, id := range ids {
+			team = append(team, rating(id))
+		}
+		return team
+	}
+	if p := (elo{}).WinProbability(team(red), team(blue)); math.Abs(p-0.5) > 0.05 {
+		t.Errorf("red wins with probability %.2f", p)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnpick(t *testing.T) {
+	tests := []struct {
+		name string
+		// Who undoes the pick: the captain who made it, the other one or an admin.
+		who string
+		// How the pick was made: by the captain or by the pick timer with
+		// this idlecaptain setting.
+		idleCaptain string
+		want        string
+		undone      bool
+	}{
+		{"own pick", "captain", "", "is back to pick from", true},
+		{"other captain", "other", "", "Only the captain who made the last pick", false},
+		{"admin", "admin", "", "is back to pick from", true},
+		{"picked by the timer", "captain", "earliest", "is back to pick from", true},
+		{"passed captaincy", "admin", "pass", "is captain now and can't be unpicked", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := []string{"countdown 0", "pickorder alternate"}
+			if test.idleCaptain != "" {
+				settings = append(settings, "picktime 5m", "idlecaptain "+test.idleCaptain)
+			}
+			tb := newTestBot(t, 6, settings...)
+			tb.join(1, 6)
+			g := GameIdentifier{testChannel, "ctf"}
+			if test.idleCaptain != "" {
+				tb.lock()
+				tb.expirePick(g, tb.mod())
+				tb.unlock()
+			} else {
+				tb.pickTurn()
+			}
+
+			game := tb.game()
+			last := game.Picks[len(game.Picks)-1]
+			left := len(game.Players)
+			switch test.who {
+			case "captain":
+				tb.run(User{ID: last.Captain}, ".unpick")
+			case "other":
+				tb.run(User{ID: game.captain(!last.Team)}, ".unpick ctf")
+			default:
+				tb.admin(".unpick")
+			}
+			if sent := strings.Join(tb.messenger.Sent(testChannel), "\n"); !strings.Contains(sent, test.want) {
+				t.Errorf("sent %q, want %q", sent, test.want)
+			}
+			undone := tb.game().HasPlayer(last.Player) && len(tb.game().Players) == left+1
+			if undone != test.undone {
+				t.Errorf("pick undone %v, want %v", undone, test.undone)
+			}
+			if color, _ := tb.game().PickTurn(tb.mod()); undone && color != last.Team {
+				t.Errorf("%s's turn after undoing %s's pick", color, last.Team)
+			}
+		})
+	}
+}
+
+func TestUnpickNothing(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 0")
+	tb.run(testUser(1), ".unpick")
+	tb.expectSent("No game is picking")
+	tb.join(1, 4)
+	tb.admin(".unpick ctf")
+	tb.expectSent("Nobody was picked in **ctf** yet")
+}
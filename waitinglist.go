@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Returns the position of a player on the waiting list, counting from 1, or 0
+// if they aren't on it.
+func (game *Game) waitingPosition(userID string) int {
+	for i, player := range game.Waiting {
+		if player.Key == userID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Puts a player at the end of the waiting list unless they are on it already.
+// Returns their position.
+func (game *Game) addWaiting(user User, roles []string) int {
+	if position := game.waitingPosition(user.ID); position > 0 {
+		return position
+	}
+	log.Printf("Adding player %s (%s) to the waiting list of %p", user.DisplayName(), user.ID, game)
+	game.Waiting = append(game.Waiting, Player{user.ID, &PlayerMetadata{Name: user.DisplayName(), JoinTime: time.Now(), LastSeenTime: time.Now(), Roles: roles}})
+	return len(game.Waiting)
+}
+
+// Takes a player off the waiting list. Returns false if they weren't on it.
+func (game *Game) removeWaiting(userID string) bool {
+	position := game.waitingPosition(userID)
+	if position == 0 {
+		return false
+	}
+	game.Waiting = append(game.Waiting[:position-1], game.Waiting[position:]...)
+	return true
+}
+
+// Whether a player is in the game, picked or not.
+func (game *Game) isPlaying(userID string) bool {
+	_, red := game.Red[userID]
+	_, blue := game.Blue[userID]
+	return game.HasPlayer(userID) || red || blue
+}
+
+// Moves players from the waiting list into the game while it has room.
+// Returns the user IDs of those who moved up.
+func (game *Game) promoteWaiting(mod *Mod) []string {
+	var promoted []string
+	for len(game.Waiting) > 0 && !game.IsFull(mod) {
+		player := game.Waiting[0]
+		game.Waiting = game.Waiting[1:]
+		if game.isPlaying(player.Key) {
+			continue
+		}
+		game.Players[player.Key] = player.Value
+		promoted = append(promoted, player.Key)
+	}
+	return promoted
+}
+
+// Fills a game that has room again, e.g. after someone left or when a new
+// queue starts, from its waiting list. The players who move up are told, and
+// picking begins if the game is full. Nothing changes once captains are known.
+func (b *Bot) refill(g GameIdentifier) {
+	game := b.games[g]
+	mod := b.channels[g.Channel].Mods[g.Mod]
+	if *game.RedCaptain != "" && *game.BlueCaptain != "" {
+		return
+	}
+	promoted := game.promoteWaiting(mod)
+	if len(promoted) == 0 {
+		return
+	}
+	var mentions []string
+	for _, id := range promoted {
+		mentions = append(mentions, mention(id))
+	}
+	b.messenger.Send(g.Channel, fmt.Sprintf("%s moved up from the waiting list of **%s** [%d / %d]",
+		strings.Join(mentions, " "), g.Mod, len(game.Players)+len(game.Red)+len(game.Blue), mod.MaxPlayers))
+	if game.IsFull(mod) {
+		b.beginPicks(g, mod)
+	}
+	b.saveGame(g)
+}
+
+// Lists the names on the waiting list of a game, first to move up first.
+func (game *Game) waitingList() string {
+	var names []string
+	for _, player := range game.Waiting {
+		names = append(names, player.Value.Name)
+	}
+	return strings.Join(names, " :small_orange_diamond: ")
+}
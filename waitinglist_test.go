@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitingList(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 10m")
+	tb.join(1, 6)
+	tb.expectSent("**ctf** is full, Player6 is **#2** on the waiting list")
+
+	tb.run(testUser(2), ".l ctf")
+	tb.expectSent("<@100000000000000005> moved up from the waiting list of **ctf** [4 / 4]")
+	if game := tb.game(); !game.HasPlayer(testUser(5).ID) || game.waitingPosition(testUser(6).ID) != 1 {
+		t.Fatalf("Player5 should play and Player6 wait first, waiting: %v", game.waitingList())
+	}
+
+	tb.admin(".frc ctf")
+	left := tb.game().PlayersSortedByJoinTime()[0]
+	tb.run(User{ID: left.Key}, ".l ctf")
+	if !tb.game().HasPlayer(left.Key) {
+		t.Errorf("%s left while teams were picked", left.Value.Name)
+	}
+	tb.expectSent("Teams of **ctf** are being picked, you can't leave now")
+
+	for tb.game().IsPickingTeams(tb.mod()) {
+		tb.pickTurn()
+	}
+	tb.expectSent("<@100000000000000006> moved up from the waiting list of **ctf** [1 / 4]")
+	if game := tb.game(); !game.HasPlayer(testUser(6).ID) || len(game.Waiting) != 0 {
+		t.Errorf("Player6 should be in the next game")
+	}
+}
+
+func TestWaitingListTimesOut(t *testing.T) {
+	tb := newTestBot(t, 4, "countdown 10m")
+	tb.admin(".settimeout 60")
+	tb.join(1, 6)
+	game := tb.game()
+	for _, player := range game.Waiting {
+		player.Value.LastSeenTime = time.Now().Add(-2 * time.Hour)
+	}
+
+	// Player6 chats while waiting, Player5 left long ago.
+	tb.keepAlive(testUser(6).ID)
+	tb.cleanupPlayers()
+	tb.expectSent("<@100000000000000005> was removed from the waiting list of ctf because they timed out")
+	if game := tb.game(); game.waitingPosition(testUser(5).ID) != 0 || game.waitingPosition(testUser(6).ID) != 1 {
+		t.Fatalf("waiting: %s, want only Player6", game.waitingList())
+	}
+
+	tb.run(testUser(1), ".l ctf")
+	if game := tb.game(); !game.HasPlayer(testUser(6).ID) || game.HasPlayer(testUser(5).ID) {
+		t.Errorf("Player6 should have moved up, not Player5")
+	}
+}